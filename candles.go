@@ -0,0 +1,183 @@
+package wallex
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resolution is a candle timeframe in UDF notation: a number of minutes,
+// such as "15", or a number of days or weeks suffixed with "D" or "W",
+// such as "1D".
+type Resolution string
+
+// List of common candle resolutions. Only the native ones, Minute, Hour,
+// ThreeHour, SixHour, TwelveHour and Day, are served by the API; others are
+// resampled from them.
+const (
+	Minute        Resolution = "1"
+	FiveMinute    Resolution = "5"
+	FifteenMinute Resolution = "15"
+	ThirtyMinute  Resolution = "30"
+	Hour          Resolution = "60"
+	ThreeHour     Resolution = "180"
+	FourHour      Resolution = "240"
+	SixHour       Resolution = "360"
+	TwelveHour    Resolution = "720"
+	Day           Resolution = "1D"
+	Week          Resolution = "1W"
+)
+
+// nativeResolutions are the resolutions served by the API, coarsest first.
+var nativeResolutions = []Resolution{Day, TwelveHour, SixHour, ThreeHour, Hour, Minute}
+
+// ErrInvalidResolution is returned when a resolution is malformed.
+var ErrInvalidResolution = &Error{Message: "invalid resolution"}
+
+// ParseResolution parses a resolution in UDF notation, such as "15" or
+// "1D", or as a count and a unit, such as "15m", "4h", "1d" or "1w", and
+// returns it in UDF notation.
+func ParseResolution(s string) (Resolution, error) {
+	invalid := &Error{
+		Message: fmt.Sprintf("%s %q", ErrInvalidResolution.Message, s),
+		kind:    ErrInvalidResolution,
+	}
+	if s == "" {
+		return "", invalid
+	}
+	count, unit := s[:len(s)-1], s[len(s)-1]
+	if unit >= '0' && unit <= '9' {
+		count, unit = s, 'm'
+	}
+	if count == "" {
+		count = "1"
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 || count[0] == '+' {
+		return "", invalid
+	}
+	switch unit {
+	case 'm':
+		return Resolution(strconv.Itoa(n)), nil
+	case 'h', 'H':
+		return Resolution(strconv.Itoa(n * 60)), nil
+	case 'd', 'D':
+		return Resolution(strconv.Itoa(n) + "D"), nil
+	case 'w', 'W':
+		return Resolution(strconv.Itoa(n) + "W"), nil
+	}
+	return "", invalid
+}
+
+// Validate checks that r is in UDF notation.
+func (r Resolution) Validate() error {
+	if r.Duration() == 0 {
+		return &Error{
+			Message: fmt.Sprintf("%s %q", ErrInvalidResolution.Message, string(r)),
+			kind:    ErrInvalidResolution,
+		}
+	}
+	return nil
+}
+
+// Duration returns the length of a candle of r, or zero if r is invalid.
+func (r Resolution) Duration() time.Duration {
+	n, unit := r.split()
+	return time.Duration(n) * unit
+}
+
+// IsNative reports whether r is served by the API without resampling.
+func (r Resolution) IsNative() bool {
+	for _, n := range nativeResolutions {
+		if r == n {
+			return true
+		}
+	}
+	return false
+}
+
+// native returns the coarsest native resolution that divides r.
+func (r Resolution) native() Resolution {
+	d := r.Duration()
+	for _, n := range nativeResolutions {
+		if d%n.Duration() == 0 {
+			return n
+		}
+	}
+	return Minute
+}
+
+// split returns the count and the unit of r, or zero if r is invalid.
+func (r Resolution) split() (int, time.Duration) {
+	s, unit := string(r), time.Minute
+	switch {
+	case strings.HasSuffix(s, "D"):
+		s, unit = strings.TrimSuffix(s, "D"), 24*time.Hour
+	case strings.HasSuffix(s, "W"):
+		s, unit = strings.TrimSuffix(s, "W"), 7*24*time.Hour
+	}
+	if s == "" || s[0] < '1' || s[0] > '9' {
+		return 0, 0
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, 0
+	}
+	return n, unit
+}
+
+const (
+	// maxCandlesPerRequest is the number of candles requested at once.
+	// The server truncates longer ranges silently.
+	maxCandlesPerRequest = 500
+
+	// candleConcurrency is the number of candle windows fetched at once.
+	candleConcurrency = 4
+)
+
+type candleWindow struct {
+	from, to time.Time
+}
+
+// candleWindows splits [from, to] into windows of at most
+// maxCandlesPerRequest candles. Both ends of a window are inclusive.
+func candleWindows(resolution Resolution, from, to time.Time) []candleWindow {
+	if !from.Before(to) {
+		return []candleWindow{{from, to}}
+	}
+	step := resolution.Duration() * maxCandlesPerRequest
+
+	var windows []candleWindow
+	for start := from; !start.After(to); start = start.Add(step) {
+		end := start.Add(step - time.Second)
+		if end.After(to) {
+			end = to
+		}
+		windows = append(windows, candleWindow{start, end})
+	}
+	return windows
+}
+
+// mergeCandles flattens the candles of several windows, sorts them by time
+// and drops duplicates of the same timestamp, keeping the last one.
+func mergeCandles(windows [][]*Candle) []*Candle {
+	var candles []*Candle
+	for _, w := range windows {
+		candles = append(candles, w...)
+	}
+	sort.SliceStable(candles, func(i, j int) bool {
+		return candles[i].Timestamp.Before(candles[j].Timestamp)
+	})
+
+	merged := candles[:0]
+	for _, c := range candles {
+		if n := len(merged); n > 0 && merged[n-1].Timestamp.Equal(c.Timestamp) {
+			merged[n-1] = c
+			continue
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
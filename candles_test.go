@@ -0,0 +1,211 @@
+package wallex
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCandleWindows(t *testing.T) {
+	from := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		resolution Resolution
+		to         time.Time
+		windows    int
+	}{
+		{Hour, from, 1},
+		{Hour, from.Add(499 * time.Hour), 1}, // Exactly 500 candles.
+		{Hour, from.Add(500 * time.Hour), 2}, // 501 candles.
+		{Hour, from.Add(1000*time.Hour - time.Second), 2},
+		{Hour, from.Add(1000 * time.Hour), 3},
+		{Minute, from.Add(499 * time.Minute), 1},
+		{Minute, from.Add(500 * time.Minute), 2},
+		{Day, from.AddDate(0, 0, 499), 1},
+		{Day, from.AddDate(0, 0, 1500), 4},
+	}
+	for _, tt := range tests {
+		windows := candleWindows(tt.resolution, from, tt.to)
+		if len(windows) != tt.windows {
+			t.Errorf("candleWindows(%s, %v) returned %d windows, want %d", tt.resolution, tt.to.Sub(from), len(windows), tt.windows)
+			continue
+		}
+		// Windows cover the interval without overlapping, each with at
+		// most 500 candles.
+		if first, last := windows[0], windows[len(windows)-1]; !first.from.Equal(from) || !last.to.Equal(tt.to) {
+			t.Errorf("candleWindows(%s, %v) covers %v to %v", tt.resolution, tt.to.Sub(from), first.from, last.to)
+		}
+		for i, w := range windows {
+			if n := w.to.Sub(w.from)/tt.resolution.Duration() + 1; n > maxCandlesPerRequest {
+				t.Errorf("candleWindows(%s, %v)[%d] spans %d candles", tt.resolution, tt.to.Sub(from), i, n)
+			}
+			if i > 0 && !w.from.Equal(windows[i-1].to.Add(time.Second)) {
+				t.Errorf("candleWindows(%s, %v)[%d] starts at %v after %v", tt.resolution, tt.to.Sub(from), i, w.from, windows[i-1].to)
+			}
+		}
+	}
+
+	// An empty or reversed interval is requested as is.
+	if windows := candleWindows(Hour, from, from.Add(-time.Hour)); len(windows) != 1 {
+		t.Errorf("candleWindows of a reversed interval returned %d windows, want 1", len(windows))
+	}
+}
+
+func TestMergeCandles(t *testing.T) {
+	at := func(hour int, cl Number) *Candle {
+		return &Candle{Timestamp: time.Unix(int64(hour)*3600, 0), Close: cl}
+	}
+	merged := mergeCandles([][]*Candle{
+		{at(3, "3"), at(4, "4")},
+		{at(1, "1"), at(2, "2"), at(3, "3.1")},
+		nil,
+		{at(4, "4.1"), at(5, "5")},
+	})
+	var got []Number
+	for _, c := range merged {
+		got = append(got, c.Close)
+	}
+	// Duplicates keep the candle of the last window.
+	want := []Number{"1", "2", "3.1", "4.1", "5"}
+	if len(got) != len(want) {
+		t.Fatalf("merged closes = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("merged closes = %v, want %v", got, want)
+		}
+	}
+}
+
+// udfServer serves the UDF history of hourly candles and, like the real
+// API, silently truncates responses to maxCandlesPerRequest candles.
+type udfServer struct {
+	mu       sync.Mutex
+	candles  []*Candle
+	overlap  bool   // Also serve the candle before each window.
+	errmsg   string // Fail requests with this message.
+	requests int
+}
+
+func newUDFServer(t *testing.T) (*Client, *udfServer) {
+	s := &udfServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests++
+		if s.errmsg != "" {
+			json.NewEncoder(w).Encode(map[string]string{"s": "error", "errmsg": s.errmsg})
+			return
+		}
+		q := r.URL.Query()
+		from, _ := strconv.ParseInt(q.Get("from"), 10, 64)
+		to, _ := strconv.ParseInt(q.Get("to"), 10, 64)
+		if s.overlap {
+			from -= 3600
+		}
+		result := map[string][]interface{}{}
+		n := 0
+		for _, c := range s.candles {
+			if ts := c.Timestamp.Unix(); ts < from || ts > to || n == maxCandlesPerRequest {
+				continue
+			}
+			n++
+			result["t"] = append(result["t"], c.Timestamp.Unix())
+			result["o"] = append(result["o"], c.Open)
+			result["h"] = append(result["h"], c.High)
+			result["l"] = append(result["l"], c.Low)
+			result["c"] = append(result["c"], c.Close)
+			result["v"] = append(result["v"], c.Volume)
+		}
+		if n == 0 {
+			json.NewEncoder(w).Encode(map[string]string{"s": "no_data"})
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(srv.Close)
+	return New(ClientOptions{Environment: LocalEnvironment(srv.URL)}), s
+}
+
+// hourlyCandles returns n hourly candles from start, closing at their index.
+func hourlyCandles(start time.Time, n int) []*Candle {
+	candles := make([]*Candle, n)
+	for i := range candles {
+		v := Number(strconv.Itoa(i))
+		candles[i] = &Candle{Timestamp: start.Add(time.Duration(i) * time.Hour), Open: v, High: v, Low: v, Close: v, Volume: "1"}
+	}
+	return candles
+}
+
+func TestCandlesPagination(t *testing.T) {
+	start := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	c, s := newUDFServer(t)
+	s.candles = hourlyCandles(start, 1200)
+
+	tests := []struct {
+		n        int
+		overlap  bool
+		requests int
+	}{
+		{500, false, 1},
+		{501, false, 2},
+		{1000, false, 2},
+		{1200, false, 3},
+		// Windows that overlap on the server side are deduplicated.
+		{1200, true, 3},
+	}
+	for _, tt := range tests {
+		s.overlap, s.requests = tt.overlap, 0
+		to := start.Add(time.Duration(tt.n-1) * time.Hour)
+		candles, err := c.Candles("BTCTMN", Hour, start, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.requests != tt.requests {
+			t.Errorf("%d candles took %d requests, want %d", tt.n, s.requests, tt.requests)
+		}
+		if len(candles) != tt.n {
+			t.Errorf("got %d candles, want %d", len(candles), tt.n)
+			continue
+		}
+		for i, c := range candles {
+			if !c.Timestamp.Equal(start.Add(time.Duration(i)*time.Hour)) || c.Close != Number(strconv.Itoa(i)) {
+				t.Errorf("candle %d = %+v", i, c)
+				break
+			}
+		}
+	}
+}
+
+func TestCandlesNoData(t *testing.T) {
+	start := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	c, s := newUDFServer(t)
+	s.candles = hourlyCandles(start.Add(600*time.Hour), 10)
+
+	// Windows without data are skipped as long as one has some.
+	candles, err := c.Candles("BTCTMN", Hour, start, start.Add(1200*time.Hour))
+	if err != nil || len(candles) != 10 {
+		t.Errorf("Candles = %d candles, %v, want 10", len(candles), err)
+	}
+
+	_, err = c.Candles("BTCTMN", Hour, start, start.Add(500*time.Hour))
+	if !errors.Is(err, ErrNoData) {
+		t.Errorf("Candles of an empty interval = %v, want ErrNoData", err)
+	}
+}
+
+func TestCandlesServerError(t *testing.T) {
+	start := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	c, s := newUDFServer(t)
+	s.errmsg = "invalid range"
+
+	_, err := c.Candles("BTCTMN", Hour, start, start.Add(time.Hour))
+	var e *Error
+	if !errors.Is(err, ErrBadRequest) || !errors.As(err, &e) || e.ServerMessage != "invalid range" {
+		t.Errorf("Candles = %v, want ErrBadRequest with the server message", err)
+	}
+}
@@ -0,0 +1,269 @@
+package wallex
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CatalogOptions customizes a MarketCatalog.
+type CatalogOptions struct {
+
+	// TTL is how long fetched markets are served before they are
+	// refreshed. If zero, it defaults to 5 minutes.
+	TTL time.Duration
+
+	// OnChange, if not nil, is called after a refresh that found newly
+	// listed or delisted markets.
+	OnChange func(*CatalogDiff)
+}
+
+// CatalogDiff lists the markets listed and delisted between two refreshes
+// of a MarketCatalog, sorted by symbol.
+type CatalogDiff struct {
+	Listed   []*Market
+	Delisted []*Market
+}
+
+// Empty reports whether d has no changes.
+func (d *CatalogDiff) Empty() bool {
+	return len(d.Listed) == 0 && len(d.Delisted) == 0
+}
+
+// MarketCatalog is a cache of markets, indexed by symbol, base asset and
+// quote asset. Markets are fetched on first use and refreshed once they are
+// older than the TTL. It is safe for concurrent use.
+type MarketCatalog struct {
+	client *Client
+	opt    CatalogOptions
+
+	mu        sync.RWMutex
+	markets   []*Market
+	bySymbol  map[string]*Market
+	byBase    map[string][]*Market
+	byQuote   map[string][]*Market
+	fetchedAt time.Time
+
+	// flight is the refresh in progress, if any, shared by concurrent
+	// callers of a stale catalog.
+	flight *catalogFlight
+}
+
+// catalogFlight is a refresh of a MarketCatalog. Its results are set
+// before done is closed.
+type catalogFlight struct {
+	done chan struct{}
+	diff *CatalogDiff
+	err  error
+}
+
+// NewMarketCatalog returns an empty catalog of the markets of c.
+func NewMarketCatalog(c *Client, opt CatalogOptions) *MarketCatalog {
+	if opt.TTL <= 0 {
+		opt.TTL = 5 * time.Minute
+	}
+	return &MarketCatalog{client: c, opt: opt}
+}
+
+// Refresh fetches markets regardless of the TTL and returns the markets
+// listed and delisted since the previous fetch. The first fetch reports no
+// changes. Concurrent calls share a single request.
+func (m *MarketCatalog) Refresh(ctx context.Context) (*CatalogDiff, error) {
+	return m.refresh(ctx, true)
+}
+
+// ensure refreshes m if it is empty or older than the TTL.
+func (m *MarketCatalog) ensure(ctx context.Context) error {
+	if m.fresh() {
+		return nil
+	}
+	_, err := m.refresh(ctx, false)
+	return err
+}
+
+// refresh starts a refresh, or joins the one in progress, and waits until
+// it is done or ctx is. Unless force is set, nothing is fetched if m is
+// fresh, e.g. because it was refreshed by another caller meanwhile.
+func (m *MarketCatalog) refresh(ctx context.Context, force bool) (*CatalogDiff, error) {
+	for {
+		m.mu.Lock()
+		if !force && m.freshLocked() {
+			m.mu.Unlock()
+			return &CatalogDiff{}, nil
+		}
+		f := m.flight
+		if f == nil {
+			f = &catalogFlight{done: make(chan struct{})}
+			m.flight = f
+			go m.fetch(ctx, f)
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if ctx.Err() == nil && isContextError(f.err) {
+			// The caller that started the refresh gave up, but this
+			// one did not; try again.
+			continue
+		}
+		return f.diff, f.err
+	}
+}
+
+// fetch performs the refresh f with the context of the caller that
+// started it.
+func (m *MarketCatalog) fetch(ctx context.Context, f *catalogFlight) {
+	f.diff, f.err = m.update(ctx)
+	m.mu.Lock()
+	m.flight = nil
+	m.mu.Unlock()
+	close(f.done)
+}
+
+func (m *MarketCatalog) update(ctx context.Context) (*CatalogDiff, error) {
+	markets, err := m.client.MarketsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bySymbol := make(map[string]*Market, len(markets))
+	byBase := map[string][]*Market{}
+	byQuote := map[string][]*Market{}
+	for _, mk := range markets {
+		bySymbol[mk.Symbol] = mk
+		byBase[mk.BaseAsset] = append(byBase[mk.BaseAsset], mk)
+		byQuote[mk.QuoteAsset] = append(byQuote[mk.QuoteAsset], mk)
+	}
+
+	m.mu.Lock()
+	diff := &CatalogDiff{}
+	if m.bySymbol != nil {
+		for _, mk := range markets {
+			if _, ok := m.bySymbol[mk.Symbol]; !ok {
+				diff.Listed = append(diff.Listed, mk)
+			}
+		}
+		for _, mk := range m.markets {
+			if _, ok := bySymbol[mk.Symbol]; !ok {
+				diff.Delisted = append(diff.Delisted, mk)
+			}
+		}
+	}
+	m.markets = markets
+	m.bySymbol = bySymbol
+	m.byBase = byBase
+	m.byQuote = byQuote
+	m.fetchedAt = time.Now()
+	m.mu.Unlock()
+
+	if m.opt.OnChange != nil && !diff.Empty() {
+		m.opt.OnChange(diff)
+	}
+	return diff, nil
+}
+
+func (m *MarketCatalog) fresh() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.freshLocked()
+}
+
+// freshLocked must be called with m.mu held.
+func (m *MarketCatalog) freshLocked() bool {
+	return m.bySymbol != nil && time.Since(m.fetchedAt) < m.opt.TTL
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// FetchedAt returns the time markets were last fetched, or the zero time
+// if they were never fetched.
+func (m *MarketCatalog) FetchedAt() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.fetchedAt
+}
+
+// Market returns the market of symbol. The error matches ErrNotFound if
+// there is no such market.
+func (m *MarketCatalog) Market(ctx context.Context, symbol string) (*Market, error) {
+	if err := m.ensure(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mk, ok := m.bySymbol[symbol]
+	if !ok {
+		return nil, &Error{
+			Message: "market " + symbol + " not found",
+			kind:    ErrNotFound,
+		}
+	}
+	return mk, nil
+}
+
+// Markets returns all markets, sorted by symbol.
+func (m *MarketCatalog) Markets(ctx context.Context) ([]*Market, error) {
+	if err := m.ensure(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]*Market(nil), m.markets...), nil
+}
+
+// Symbols returns the symbols of all markets, sorted.
+func (m *MarketCatalog) Symbols(ctx context.Context) ([]string, error) {
+	markets, err := m.Markets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]string, len(markets))
+	for i, mk := range markets {
+		symbols[i] = mk.Symbol
+	}
+	return symbols, nil
+}
+
+// ByBase returns the markets trading asset, e.g. all BTC pairs,
+// sorted by symbol.
+func (m *MarketCatalog) ByBase(ctx context.Context, asset string) ([]*Market, error) {
+	if err := m.ensure(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]*Market(nil), m.byBase[asset]...), nil
+}
+
+// ByQuote returns the markets quoted in asset, e.g. all TMN or all USDT
+// pairs, sorted by symbol.
+func (m *MarketCatalog) ByQuote(ctx context.Context, asset string) ([]*Market, error) {
+	if err := m.ensure(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]*Market(nil), m.byQuote[asset]...), nil
+}
+
+// QuoteAssets returns the assets markets are quoted in, sorted.
+func (m *MarketCatalog) QuoteAssets(ctx context.Context) ([]string, error) {
+	if err := m.ensure(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	assets := make([]string, 0, len(m.byQuote))
+	for a := range m.byQuote {
+		assets = append(assets, a)
+	}
+	sort.Strings(assets)
+	return assets, nil
+}
@@ -2,17 +2,20 @@ package wallex
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	baseURL      = "https://api.wallex.ir"
 	apiKeyHeader = "x-api-key"
 )
 
@@ -20,19 +23,108 @@ const (
 type Error struct {
 	Message string
 	Cause   error
+
+	// StatusCode is the HTTP status code of a failed response, if any.
+	StatusCode int
+
+	// Code is the error code reported by the server, if any.
+	Code string
+
+	// ServerMessage is the error message reported by the server, if any.
+	ServerMessage string
+
+	// Fields maps request fields to their validation errors, if any.
+	Fields map[string][]string
+
+	// kind is the sentinel error that e is an instance of.
+	kind *Error
 }
 
 func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("wallex: ")
+	b.WriteString(e.Message)
+	if e.ServerMessage != "" {
+		b.WriteString(": ")
+		b.WriteString(e.ServerMessage)
+	}
+	if len(e.Fields) > 0 {
+		fields := make([]string, 0, len(e.Fields))
+		for f := range e.Fields {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		b.WriteString(" (")
+		for i, f := range fields {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			b.WriteString(f)
+			b.WriteString(": ")
+			b.WriteString(strings.Join(e.Fields[f], ", "))
+		}
+		b.WriteString(")")
+	}
 	if e.Cause != nil {
-		return fmt.Sprintf("wallex: %s: %v", e.Message, e.Cause)
+		fmt.Fprintf(&b, ": %v", e.Cause)
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying cause of e, if any.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether e is an instance of target,
+// so that errors.Is works against the sentinel errors.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && e.kind != nil && e.kind == t
+}
+
+// Environment is a set of endpoints where Wallex API is served.
+type Environment struct {
+	BaseURL   string
+	StreamURL string
+}
+
+// Production is the public Wallex API. Use LocalEnvironment for other
+// deployments, e.g. a staging mirror or a caching gateway.
+var Production = Environment{
+	BaseURL:   "https://api.wallex.ir",
+	StreamURL: "wss://api.wallex.ir",
+}
+
+// LocalEnvironment returns an environment that serves both REST and streaming
+// endpoints from baseURL, e.g. an httptest server or a caching gateway.
+func LocalEnvironment(baseURL string) Environment {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	streamURL := baseURL
+	if strings.HasPrefix(streamURL, "https://") {
+		streamURL = "wss://" + strings.TrimPrefix(streamURL, "https://")
+	} else if strings.HasPrefix(streamURL, "http://") {
+		streamURL = "ws://" + strings.TrimPrefix(streamURL, "http://")
+	}
+	return Environment{
+		BaseURL:   baseURL,
+		StreamURL: streamURL,
 	}
-	return fmt.Sprintf("wallex: %s", e.Message)
 }
 
 // Client provides idiomatic methods to call Wallex API.
 type Client struct {
 	httpClient *http.Client
 	apiKey     string
+	baseURL    string
+	streamURL  string
+	retry      *RetryPolicy
+	limiter    *RateLimiter
+	handler    Handler
+	logger     Logger
+	logBodies  bool
+	metrics    *Metrics
+	strict     bool
 }
 
 // ClientOptions customizes client's properties.
@@ -45,12 +137,52 @@ type ClientOptions struct {
 	// HTTPClient is used to establish connection and to send HTTP requests.
 	// If nil, it defaults to http.DefaultClient.
 	HTTPClient *http.Client
+
+	// Environment selects the endpoints the client talks to.
+	// If its BaseURL is empty, it defaults to Production.
+	Environment Environment
+
+	// BaseURL overrides the REST endpoint of Environment if not empty.
+	BaseURL string
+
+	// StreamURL overrides the streaming endpoint of Environment if not empty.
+	StreamURL string
+
+	// Retry controls retrying of failed requests.
+	// If nil, failed requests are not retried.
+	Retry *RetryPolicy
+
+	// RateLimiter throttles outgoing requests, including retries.
+	// If nil, requests are not throttled.
+	RateLimiter *RateLimiter
+
+	// Middleware wraps every outgoing request, including retries.
+	// The first middleware is the outermost one.
+	Middleware []Middleware
+
+	// Logger receives an event for every request attempt.
+	// API key and personal information are always redacted.
+	Logger Logger
+
+	// LogBodies makes log events include response bodies.
+	LogBodies bool
+
+	// Metrics collects statistics of requests and rate limiter waits.
+	// If nil, no statistics are collected.
+	Metrics *Metrics
+
+	// StrictNumbers makes responses with invalid numbers fail with an error
+	// that matches ErrInvalidNumber, as with DecodeStrict. By default, such
+	// numbers are decoded as undefined.
+	StrictNumbers bool
 }
 
 // New instantiates a new Client.
 func New(opt ClientOptions) *Client {
 	c := &Client{}
-	if opt.APIKey == "" {
+	if opt.APIKey != "" {
+		c.apiKey = opt.APIKey
+	} else {
 		c.apiKey = os.Getenv("WALLEX_API_KEY")
 	}
 	if opt.HTTPClient != nil {
@@ -58,77 +190,110 @@ func New(opt ClientOptions) *Client {
 	} else {
 		c.httpClient = http.DefaultClient
 	}
+	env := opt.Environment
+	if env.BaseURL == "" {
+		env = Production
+	}
+	c.baseURL = env.BaseURL
+	c.streamURL = env.StreamURL
+	if opt.BaseURL != "" {
+		c.baseURL = opt.BaseURL
+	}
+	if opt.StreamURL != "" {
+		c.streamURL = opt.StreamURL
+	}
+	c.baseURL = strings.TrimSuffix(c.baseURL, "/")
+	c.streamURL = strings.TrimSuffix(c.streamURL, "/")
+	c.retry = opt.Retry
+	c.limiter = opt.RateLimiter
+	c.handler = chain(c.httpClient.Do, opt.Middleware)
+	c.logger = opt.Logger
+	c.logBodies = opt.LogBodies
+	c.metrics = opt.Metrics
+	c.strict = opt.StrictNumbers
 	return c
 }
 
+// RateLimiter returns the rate limiter of c, or nil if it has none.
+func (c *Client) RateLimiter() *RateLimiter {
+	return c.limiter
+}
+
 // -----------------------------------------------------------------------------
 // Markets
 // -----------------------------------------------------------------------------
 
 // Market represents a market information.
 type Market struct {
-	Symbol             string `json:"symbol"`
-	BaseAsset          string `json:"baseAsset"`
-	BaseAssetPrecision int    `json:"baseAssetPrecision"`
-	QuoteAsset         string `json:"quoteAsset"`
-	QuotePrecision     int    `json:"quotePrecision"`
-	FarsiName          string `json:"faName"`
-	FarsiBaseAsset     string `json:"faBaseAsset"`
-	FarsiQuoteAsset    string `json:"faQuoteAsset"`
-	StepSize           int    `json:"stepSize"`
-	TickSize           int    `json:"tickSize"`
-	MinQty             Number `json:"minQty"`
-	MinNotional        Number `json:"minNotional"`
-	Stats              struct {
-		BidPrice       Number `json:"bidPrice"`
-		AskPrice       Number `json:"askPrice"`
-		Change24H      Number `json:"24h_ch"`
-		Change7D       Number `json:"7d_ch"`
-		Volume24H      Number `json:"24h_volume"`
-		Volume7D       Number `json:"7d_volume"`
-		QuoteVolume24H Number `json:"24h_quoteVolume"`
-		HighPrice24H   Number `json:"24h_highPrice"`
-		LowPrice24H    Number `json:"24h_lowPrice"`
-		LastPrice      Number `json:"lastPrice"`
-		LastQty        Number `json:"lastQty"`
-		LastTradeSide  string `json:"lastTradeSide"`
-		BidVolume      Number `json:"bidVolume"`
-		AskVolume      Number `json:"askVolume"`
-		BidCount       Number `json:"bidCount"`
-		AskCount       Number `json:"askCount"`
-		Direction      struct {
-			Sell int `json:"SELL"`
-			Buy  int `json:"BUY"`
-		} `json:"direction"`
-	} `json:"stats"`
-	CreatedAt time.Time `json:"createdAt"`
-}
-
-// Markets retrieves a list of all available markets and their stats.
-func (c *Client) Markets() ([]*Market, error) {
-	resp, err := c.httpClient.Get(baseURL + "/v1/markets")
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
+	Symbol             string      `json:"symbol"`
+	BaseAsset          string      `json:"baseAsset"`
+	BaseAssetPrecision int         `json:"baseAssetPrecision"`
+	QuoteAsset         string      `json:"quoteAsset"`
+	QuotePrecision     int         `json:"quotePrecision"`
+	FarsiName          string      `json:"faName"`
+	FarsiBaseAsset     string      `json:"faBaseAsset"`
+	FarsiQuoteAsset    string      `json:"faQuoteAsset"`
+	StepSize           int         `json:"stepSize"`
+	TickSize           int         `json:"tickSize"`
+	MinQty             Number      `json:"minQty"`
+	MinNotional        Number      `json:"minNotional"`
+	Stats              MarketStats `json:"stats"`
+	CreatedAt          time.Time   `json:"createdAt"`
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
+// MarketStats represents the 24h statistics of a market.
+type MarketStats struct {
+	BidPrice       Number `json:"bidPrice"`
+	AskPrice       Number `json:"askPrice"`
+	Change24H      Number `json:"24h_ch"`
+	Change7D       Number `json:"7d_ch"`
+	Volume24H      Number `json:"24h_volume"`
+	Volume7D       Number `json:"7d_volume"`
+	QuoteVolume24H Number `json:"24h_quoteVolume"`
+	HighPrice24H   Number `json:"24h_highPrice"`
+	LowPrice24H    Number `json:"24h_lowPrice"`
+	LastPrice      Number `json:"lastPrice"`
+	LastQty        Number `json:"lastQty"`
+	LastTradeSide  string `json:"lastTradeSide"`
+	BidVolume      Number `json:"bidVolume"`
+	AskVolume      Number `json:"askVolume"`
+	BidCount       Number `json:"bidCount"`
+	AskCount       Number `json:"askCount"`
+	Direction      struct {
+		Sell int `json:"SELL"`
+		Buy  int `json:"BUY"`
+	} `json:"direction"`
+}
 
+// Markets retrieves a list of all available markets and their stats,
+// sorted by symbol.
+func (c *Client) Markets() ([]*Market, error) {
+	return c.MarketsContext(context.Background())
+}
+
+// MarketsContext is like Markets but uses ctx for the request.
+func (c *Client) MarketsContext(ctx context.Context) ([]*Market, error) {
 	result := struct {
 		Result struct {
 			Symbols map[string]*Market `json:"symbols"`
 		} `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "Markets",
+		method:   http.MethodGet,
+		path:     "/v1/markets",
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	markets := make([]*Market, 0, len(result.Result.Symbols))
 	for _, m := range result.Result.Symbols {
 		markets = append(markets, m)
 	}
+	sort.Slice(markets, func(i, j int) bool {
+		return markets[i].Symbol < markets[j].Symbol
+	})
 	return markets, nil
 }
 
@@ -173,21 +338,21 @@ type Currency struct {
 
 // Currencies retrieves a list of all available crypto-currencies and their stats.
 func (c *Client) Currencies() ([]*Currency, error) {
-	resp, err := c.httpClient.Get(baseURL + "/v1/currencies/stats")
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
+	return c.CurrenciesContext(context.Background())
+}
 
+// CurrenciesContext is like Currencies but uses ctx for the request.
+func (c *Client) CurrenciesContext(ctx context.Context) ([]*Currency, error) {
 	result := struct {
 		Result []*Currency `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "Currencies",
+		method:   http.MethodGet,
+		path:     "/v1/currencies/stats",
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	return result.Result, nil
@@ -202,27 +367,28 @@ type MarketOrder struct {
 
 // MarketOrders retrieves list of active orders in a market.
 func (c *Client) MarketOrders(symbol string) (ask []*MarketOrder, bid []*MarketOrder, _ error) {
+	return c.MarketOrdersContext(context.Background(), symbol)
+}
+
+// MarketOrdersContext is like MarketOrders but uses ctx for the request.
+func (c *Client) MarketOrdersContext(ctx context.Context, symbol string) (ask []*MarketOrder, bid []*MarketOrder, _ error) {
 	query := url.Values{}
 	query.Add("symbol", symbol)
 
-	resp, err := c.httpClient.Get(baseURL + "/v1/depth?" + query.Encode())
-	if err != nil {
-		return nil, nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil, errNonOKResponse(resp.StatusCode)
-	}
-
 	result := struct {
 		Result struct {
 			Ask []*MarketOrder `json:"ask"`
 			Bid []*MarketOrder `json:"bid"`
 		} `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "MarketOrders",
+		method:   http.MethodGet,
+		path:     "/v1/depth",
+		query:    query,
+	}, &result)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return result.Result.Ask, result.Result.Bid, nil
@@ -230,35 +396,37 @@ func (c *Client) MarketOrders(symbol string) (ask []*MarketOrder, bid []*MarketO
 
 // MarketTrade represents an trade in the market.
 type MarketTrade struct {
-	Symbol    string    `json:"symbol"`
-	Quantity  Number    `json:"quantity"`
-	Price     Number    `json:"price"`
-	Sum       Number    `json:"sum"`
-	Timestamp time.Time `json:"timestamp"`
+	Symbol     string    `json:"symbol"`
+	Quantity   Number    `json:"quantity"`
+	Price      Number    `json:"price"`
+	Sum        Number    `json:"sum"`
+	IsBuyOrder bool      `json:"isBuyOrder"`
+	Timestamp  time.Time `json:"timestamp"`
 }
 
 // MarketTrades retrieves list of most recent trades in a market.
 func (c *Client) MarketTrades(symbol string) ([]*MarketTrade, error) {
+	return c.MarketTradesContext(context.Background(), symbol)
+}
+
+// MarketTradesContext is like MarketTrades but uses ctx for the request.
+func (c *Client) MarketTradesContext(ctx context.Context, symbol string) ([]*MarketTrade, error) {
 	query := url.Values{}
 	query.Add("symbol", symbol)
 
-	resp, err := c.httpClient.Get(baseURL + "/v1/trades?" + query.Encode())
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
-
 	result := struct {
 		Result struct {
 			LatestTrades []*MarketTrade `json:"latestTrades"`
 		} `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "MarketTrades",
+		method:   http.MethodGet,
+		path:     "/v1/trades",
+		query:    query,
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	return result.Result.LatestTrades, nil
@@ -274,47 +442,141 @@ type Candle struct {
 	Volume    Number    `json:"volume"`
 }
 
-// List of available candle resolutions.
-const (
-	Minute     = "1"
-	Hour       = "60"
-	ThreeHour  = "180"
-	SixHour    = "360"
-	TwelveHour = "720"
-	Day        = "1D"
-)
+// ErrNoData is returned when there are no candles in the requested interval.
+var ErrNoData = &Error{Message: "no data"}
+
+// Candles retrieves OHLCV candles for the given time interval, sorted by
+// time. Long intervals are split into several requests, which are sent
+// concurrently. Resolutions the API does not serve are resampled from the
+// coarsest native resolution that divides them, aligned to UTC; use
+// Resample for other alignments. It returns an error matching ErrNoData if
+// the interval has no candles at all and one matching ErrBadRequest if the
+// server rejects the request.
+func (c *Client) Candles(symbol string, resolution Resolution, from, to time.Time) ([]*Candle, error) {
+	return c.CandlesContext(context.Background(), symbol, resolution, from, to)
+}
+
+// CandlesContext is like Candles but uses ctx for the requests.
+func (c *Client) CandlesContext(ctx context.Context, symbol string, resolution Resolution, from, to time.Time) ([]*Candle, error) {
+	if err := resolution.Validate(); err != nil {
+		return nil, err
+	}
+	if !resolution.IsNative() {
+		var opt ResampleOptions
+		candles, err := c.CandlesContext(ctx, symbol, resolution.native(), opt.align(from, resolution), to)
+		if err != nil {
+			return nil, err
+		}
+		return Resample(candles, resolution, opt)
+	}
+
+	windows := candleWindows(resolution, from, to)
+	results := make([][]*Candle, len(windows))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	next := make(chan int)
+	errs := make(chan error, candleConcurrency)
+	workers := candleConcurrency
+	if workers > len(windows) {
+		workers = len(windows)
+	}
+	for n := 0; n < workers; n++ {
+		go func() {
+			for i := range next {
+				candles, err := c.candles(ctx, symbol, resolution, windows[i].from, windows[i].to)
+				if err != nil {
+					cancel()
+					errs <- err
+					return
+				}
+				results[i] = candles
+			}
+			errs <- nil
+		}()
+	}
+feed:
+	for i := range windows {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+
+	var firstErr error
+	for n := 0; n < workers; n++ {
+		// The first error is the cause; later ones are mostly cancellations.
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	candles := mergeCandles(results)
+	if len(candles) == 0 {
+		return nil, &Error{
+			Message: ErrNoData.Message + " for " + symbol,
+			kind:    ErrNoData,
+		}
+	}
+	return candles, nil
+}
 
-// Candles retrieves OHLCV candles for the given time interval.
-func (c *Client) Candles(symbol, resolution string, from, to time.Time) ([]*Candle, error) {
+// candles retrieves candles of a single window. A window without data
+// yields no candles and no error; an error reported by the server matches
+// ErrBadRequest.
+func (c *Client) candles(ctx context.Context, symbol string, resolution Resolution, from, to time.Time) ([]*Candle, error) {
 	query := url.Values{}
 	query.Add("symbol", symbol)
-	query.Add("resolution", resolution)
+	query.Add("resolution", string(resolution))
 	query.Add("from", strconv.FormatInt(from.Unix(), 10))
 	query.Add("to", strconv.FormatInt(to.Unix(), 10))
 
-	resp, err := http.Get(baseURL + "/v1/udf/history?" + query.Encode())
+	result := struct {
+		S      string   `json:"s"`
+		ErrMsg string   `json:"errmsg"`
+		T      []int64  `json:"t"`
+		O      []string `json:"o"`
+		H      []string `json:"h"`
+		L      []string `json:"l"`
+		C      []string `json:"c"`
+		V      []string `json:"v"`
+	}{}
+	err := c.do(ctx, &request{
+		endpoint: "Candles",
+		method:   http.MethodGet,
+		path:     "/v1/udf/history",
+		query:    query,
+	}, &result)
 	if err != nil {
-		return nil, wrapRequestError(err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, errNonOKResponse(resp.StatusCode)
+	switch result.S {
+	case "ok", "":
+	case "no_data":
+		return nil, nil
+	default:
+		return nil, &Error{
+			Message:       "candles request failed",
+			ServerMessage: result.ErrMsg,
+			kind:          ErrBadRequest,
+		}
 	}
-
-	result := struct {
-		T []int64  `json:"t"`
-		O []string `json:"o"`
-		H []string `json:"h"`
-		L []string `json:"l"`
-		C []string `json:"c"`
-		V []string `json:"v"`
-	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	n := len(result.T)
+	if len(result.O) != n || len(result.H) != n || len(result.L) != n ||
+		len(result.C) != n || len(result.V) != n {
+		return nil, &Error{Message: "candles response has columns of different lengths"}
 	}
 
-	candles := make([]*Candle, 0, len(result.T))
+	candles := make([]*Candle, 0, n)
 	for i, t := range result.T {
 		candles = append(candles, &Candle{
 			Timestamp: time.Unix(t, 0),
@@ -525,30 +787,22 @@ type Profile struct {
 
 // Profile retrieves account profile.
 func (c *Client) Profile() (*Profile, error) {
-	if c.apiKey == "" {
-		return nil, ErrMissingAPIKey
-	}
-
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/account/profile", nil)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	req.Header.Add(apiKeyHeader, c.apiKey)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
+	return c.ProfileContext(context.Background())
+}
 
+// ProfileContext is like Profile but uses ctx for the request.
+func (c *Client) ProfileContext(ctx context.Context) (*Profile, error) {
 	result := struct {
 		Result *Profile `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "Profile",
+		method:   http.MethodGet,
+		path:     "/v1/account/profile",
+		private:  true,
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	return result.Result, nil
@@ -565,32 +819,24 @@ type Balance struct {
 
 // Balances retrieves a mapping between assets and their holdings.
 func (c *Client) Balances() (map[string]*Balance, error) {
-	if c.apiKey == "" {
-		return nil, ErrMissingAPIKey
-	}
-
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/account/balances", nil)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	req.Header.Add(apiKeyHeader, c.apiKey)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
+	return c.BalancesContext(context.Background())
+}
 
+// BalancesContext is like Balances but uses ctx for the request.
+func (c *Client) BalancesContext(ctx context.Context) (map[string]*Balance, error) {
 	result := struct {
 		Result struct {
 			Balances map[string]*Balance `json:"balances"`
 		} `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "Balances",
+		method:   http.MethodGet,
+		path:     "/v1/account/balances",
+		private:  true,
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	return result.Result.Balances, nil
@@ -613,32 +859,45 @@ type FeeLevel struct {
 	IsFixed       bool                      `json:"is_fixed"`
 }
 
-// FeeLevels retrieves a mapping between symbols and fee levels.
-func (c *Client) FeeLevels() (map[string]*FeeLevel, error) {
-	if c.apiKey == "" {
-		return nil, ErrMissingAPIKey
-	}
-
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/account/fee", nil)
-	if err != nil {
-		return nil, wrapRequestError(err)
+// UnmarshalJSON deserializes l from JSON data. Keys of Levels are kept as
+// is, rather than decoded as numbers, so that no level is lost or merged.
+func (l *FeeLevel) UnmarshalJSON(data []byte) error {
+	type plain FeeLevel
+	aux := struct {
+		*plain
+		Levels map[string]*NamedFeeLevel `json:"levels"`
+	}{plain: (*plain)(l)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	l.Levels = nil
+	if aux.Levels != nil {
+		l.Levels = make(map[Number]*NamedFeeLevel, len(aux.Levels))
+		for k, v := range aux.Levels {
+			l.Levels[Number(k)] = v
+		}
 	}
-	req.Header.Add(apiKeyHeader, c.apiKey)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	if resp.StatusCode != 200 {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
+// FeeLevels retrieves a mapping between symbols and fee levels.
+func (c *Client) FeeLevels() (map[string]*FeeLevel, error) {
+	return c.FeeLevelsContext(context.Background())
+}
 
+// FeeLevelsContext is like FeeLevels but uses ctx for the request.
+func (c *Client) FeeLevelsContext(ctx context.Context) (map[string]*FeeLevel, error) {
 	result := struct {
 		Result map[string]*FeeLevel `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "FeeLevels",
+		method:   http.MethodGet,
+		path:     "/v1/account/fee",
+		private:  true,
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	return result.Result, nil
@@ -655,30 +914,22 @@ type BankingCard struct {
 
 // BankingCards retrieves a list of all user's banking cards.
 func (c *Client) BankingCards() ([]*BankingCard, error) {
-	if c.apiKey == "" {
-		return nil, ErrMissingAPIKey
-	}
-
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/account/card-numbers", nil)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	req.Header.Add(apiKeyHeader, c.apiKey)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
+	return c.BankingCardsContext(context.Background())
+}
 
+// BankingCardsContext is like BankingCards but uses ctx for the request.
+func (c *Client) BankingCardsContext(ctx context.Context) ([]*BankingCard, error) {
 	result := struct {
 		Result []*BankingCard `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "BankingCards",
+		method:   http.MethodGet,
+		path:     "/v1/account/card-numbers",
+		private:  true,
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	return result.Result, nil
@@ -700,30 +951,22 @@ type BankAccount struct {
 
 // BankAccounts retrieves a list of all user's bank accounts.
 func (c *Client) BankAccounts() ([]*BankAccount, error) {
-	if c.apiKey == "" {
-		return nil, ErrMissingAPIKey
-	}
-
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/account/ibans", nil)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	req.Header.Add(apiKeyHeader, c.apiKey)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
+	return c.BankAccountsContext(context.Background())
+}
 
+// BankAccountsContext is like BankAccounts but uses ctx for the request.
+func (c *Client) BankAccountsContext(ctx context.Context) ([]*BankAccount, error) {
 	result := struct {
 		Result []*BankAccount `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "BankAccounts",
+		method:   http.MethodGet,
+		path:     "/v1/account/ibans",
+		private:  true,
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	return result.Result, nil
@@ -775,31 +1018,27 @@ type Order struct {
 
 // PlaceOrder places a new order.
 func (c *Client) PlaceOrder(p *OrderParams) (*Order, error) {
-	if c.apiKey == "" {
-		return nil, ErrMissingAPIKey
-	}
-
-	body, _ := json.Marshal(p)
-	req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/account/orders", bytes.NewReader(body))
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	req.Header.Add(apiKeyHeader, c.apiKey)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
+	return c.PlaceOrderContext(context.Background(), p)
+}
 
+// PlaceOrderContext is like PlaceOrder but uses ctx for the request.
+func (c *Client) PlaceOrderContext(ctx context.Context, p *OrderParams) (*Order, error) {
 	result := struct {
 		Result *Order `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "PlaceOrder",
+		method:   http.MethodPost,
+		path:     "/v1/account/orders",
+		body:     p,
+		private:  true,
+
+		// The server deduplicates orders by client id,
+		// so only then a retry cannot place the order twice.
+		idempotent: p.ClientID != "",
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	return result.Result, nil
@@ -807,57 +1046,41 @@ func (c *Client) PlaceOrder(p *OrderParams) (*Order, error) {
 
 // CancelOrder cancels a placed order.
 func (c *Client) CancelOrder(clientOrderID string) error {
-	if c.apiKey == "" {
-		return ErrMissingAPIKey
-	}
+	return c.CancelOrderContext(context.Background(), clientOrderID)
+}
 
+// CancelOrderContext is like CancelOrder but uses ctx for the request.
+func (c *Client) CancelOrderContext(ctx context.Context, clientOrderID string) error {
 	query := url.Values{}
 	query.Add("clientOrderId", clientOrderID)
 
-	req, err := http.NewRequest(http.MethodDelete, baseURL+"/v1/account/orders?"+query.Encode(), nil)
-	if err != nil {
-		return wrapRequestError(err)
-	}
-	req.Header.Add(apiKeyHeader, c.apiKey)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return errNonOKResponse(resp.StatusCode)
-	}
-
-	return nil
+	return c.do(ctx, &request{
+		endpoint: "CancelOrder",
+		method:   http.MethodDelete,
+		path:     "/v1/account/orders",
+		query:    query,
+		private:  true,
+	}, nil)
 }
 
 // Order retrieves details for a placed order.
 func (c *Client) Order(clientOrderID string) (*Order, error) {
-	if c.apiKey == "" {
-		return nil, ErrMissingAPIKey
-	}
-
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/account/orders/"+clientOrderID, nil)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	req.Header.Add(apiKeyHeader, c.apiKey)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
+	return c.OrderContext(context.Background(), clientOrderID)
+}
 
+// OrderContext is like Order but uses ctx for the request.
+func (c *Client) OrderContext(ctx context.Context, clientOrderID string) (*Order, error) {
 	result := struct {
 		Result *Order `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "Order",
+		method:   http.MethodGet,
+		path:     "/v1/account/orders/" + url.PathEscape(clientOrderID),
+		private:  true,
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	return result.Result, nil
@@ -866,37 +1089,30 @@ func (c *Client) Order(clientOrderID string) (*Order, error) {
 // OpenOrders retrievs a list of user's active orders.
 // If symbol is empty, it retrieves active orders for all markets.
 func (c *Client) OpenOrders(symbol string) ([]*Order, error) {
-	if c.apiKey == "" {
-		return nil, ErrMissingAPIKey
-	}
+	return c.OpenOrdersContext(context.Background(), symbol)
+}
 
+// OpenOrdersContext is like OpenOrders but uses ctx for the request.
+func (c *Client) OpenOrdersContext(ctx context.Context, symbol string) ([]*Order, error) {
 	query := url.Values{}
 	if symbol != "" {
 		query.Add("symbol", symbol)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/account/openOrders?"+query.Encode(), nil)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	req.Header.Add(apiKeyHeader, c.apiKey)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, wrapRequestError(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errNonOKResponse(resp.StatusCode)
-	}
-
 	result := struct {
 		Result struct {
 			Orders []*Order `json:"orders"`
 		} `json:"result"`
 	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	err := c.do(ctx, &request{
+		endpoint: "OpenOrders",
+		method:   http.MethodGet,
+		path:     "/v1/account/openOrders",
+		query:    query,
+		private:  true,
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
 
 	return result.Result.Orders, nil
@@ -919,10 +1135,11 @@ type Trade struct {
 // If symbol is empty, it retrieves trades for all markets.
 // If side is empty, it retrieves trades for both sides.
 func (c *Client) Trades(symbol, side string) ([]*Trade, error) {
-	if c.apiKey == "" {
-		return nil, ErrMissingAPIKey
-	}
+	return c.TradesContext(context.Background(), symbol, side)
+}
 
+// TradesContext is like Trades but uses ctx for the request.
+func (c *Client) TradesContext(ctx context.Context, symbol, side string) ([]*Trade, error) {
 	query := url.Values{}
 	if symbol != "" {
 		query.Add("symbol", symbol)
@@ -931,31 +1148,170 @@ func (c *Client) Trades(symbol, side string) ([]*Trade, error) {
 		query.Add("side", side)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/account/trades?"+query.Encode(), nil)
+	result := struct {
+		Result struct {
+			AccountLatestTrades []*Trade `json:"AccountLatestTrades"`
+		} `json:"result"`
+	}{}
+	err := c.do(ctx, &request{
+		endpoint: "Trades",
+		method:   http.MethodGet,
+		path:     "/v1/account/trades",
+		query:    query,
+		private:  true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Result.AccountLatestTrades, nil
+}
+
+// -----------------------------------------------------------------------------
+// Requests
+// -----------------------------------------------------------------------------
+
+// request describes a single call to Wallex API.
+type request struct {
+	endpoint string
+	method   string
+	path     string
+	query    url.Values
+	body     interface{}
+	private  bool
+
+	// idempotent marks non-GET requests that are safe to retry.
+	idempotent bool
+}
+
+// retryable reports whether r may be sent more than once.
+func (r *request) retryable() bool {
+	return r.method == http.MethodGet || r.idempotent
+}
+
+// do sends r and decodes the response body into result.
+// If result is nil, the response body is discarded.
+func (c *Client) do(ctx context.Context, r *request, result interface{}) error {
+	if r.private && c.apiKey == "" {
+		return ErrMissingAPIKey
+	}
+
+	var body []byte
+	if r.body != nil {
+		data, err := json.Marshal(r.body)
+		if err != nil {
+			return wrapRequestError(err)
+		}
+		body = data
+	}
+
+	for attempt := 1; ; attempt++ {
+		waited, err := c.limiter.wait(ctx, r.rateGroup())
+		if c.metrics != nil && c.limiter != nil {
+			c.metrics.observeRateLimit(r.rateGroup(), waited, err)
+		}
+		if err != nil {
+			if err == ErrRateLimited {
+				return err
+			}
+			return wrapRequestError(err)
+		}
+		retry, wait, err := c.send(ctx, r, attempt, body, result)
+		if err == nil || !retry || !r.retryable() || attempt >= c.retry.maxAttempts() {
+			return err
+		}
+		if wait <= 0 {
+			wait = c.retry.backoff(attempt)
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return wrapRequestError(err)
+		}
+	}
+}
+
+// send performs a single attempt of r. It reports whether a failed attempt
+// is worth retrying and how long the server asked to wait before that.
+func (c *Client) send(ctx context.Context, r *request, attempt int, body []byte, result interface{}) (retry bool, wait time.Duration, err error) {
+	var (
+		req      *http.Request
+		resp     *http.Response
+		respBody []byte
+	)
+	if c.logger != nil || c.metrics != nil {
+		start := time.Now()
+		defer func() {
+			latency := time.Since(start)
+			if c.metrics != nil {
+				status := 0
+				if resp != nil {
+					status = resp.StatusCode
+				}
+				c.metrics.observeRequest(r.endpoint, status, latency, err)
+			}
+			if c.logger != nil {
+				c.log(r, attempt, req, resp, respBody, latency, err)
+			}
+		}()
+	}
+
+	u := c.baseURL + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, r.method, u, reader)
 	if err != nil {
-		return nil, wrapRequestError(err)
+		return false, 0, wrapRequestError(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-	req.Header.Add(apiKeyHeader, c.apiKey)
-	resp, err := c.httpClient.Do(req)
+	if r.private {
+		req.Header.Add(apiKeyHeader, c.apiKey)
+	}
+	resp, err = c.handler(&Call{
+		Endpoint: r.endpoint,
+		Attempt:  attempt,
+		Request:  req,
+	})
 	if err != nil {
-		return nil, wrapRequestError(err)
+		return ctx.Err() == nil, 0, wrapRequestError(err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, errNonOKResponse(resp.StatusCode)
+	if c.logBodies {
+		respBody, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return ctx.Err() == nil, 0, wrapRequestError(err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
 	}
 
-	result := struct {
-		Result struct {
-			AccountLatestTrades []*Trade `json:"AccountLatestTrades"`
-		} `json:"result"`
-	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, wrapRequestError(err)
+	if resp.StatusCode != http.StatusOK {
+		return retryableStatus(resp.StatusCode), retryAfter(resp), errNonOKResponse(resp)
 	}
 
-	return result.Result.AccountLatestTrades, nil
+	if result == nil {
+		return false, 0, nil
+	}
+	if c.strict {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return ctx.Err() == nil, 0, wrapRequestError(err)
+		}
+		if err := DecodeStrict(data, result); err != nil {
+			return false, 0, wrapRequestError(err)
+		}
+		return false, 0, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return false, 0, wrapRequestError(err)
+	}
+	return false, 0, nil
 }
 
 // -----------------------------------------------------------------------------
@@ -964,14 +1320,20 @@ func (c *Client) Trades(symbol, side string) ([]*Trade, error) {
 
 // List of common service errors.
 var (
-	ErrMissingAPIKey = &Error{Message: "missing api key"}
-	ErrBadRequest    = &Error{Message: "bad request"}
-	ErrUnauthorized  = &Error{Message: "unauthorized"}
-	ErrForbidden     = &Error{Message: "access forbidden"}
-	ErrNotFound      = &Error{Message: "resource not found"}
-	ErrUnknown       = &Error{Message: "unknown error"}
+	ErrMissingAPIKey   = &Error{Message: "missing api key"}
+	ErrBadRequest      = &Error{Message: "bad request"}
+	ErrUnauthorized    = &Error{Message: "unauthorized"}
+	ErrForbidden       = &Error{Message: "access forbidden"}
+	ErrNotFound        = &Error{Message: "resource not found"}
+	ErrTooManyRequests = &Error{Message: "too many requests"}
+	ErrServer          = &Error{Message: "server error"}
+	ErrRateLimited     = &Error{Message: "client rate limit exceeded"}
+	ErrUnknown         = &Error{Message: "unknown error"}
 )
 
+// maxErrorBodySize limits how much of a failed response is read.
+const maxErrorBodySize = 1 << 20
+
 func wrapRequestError(err error) error {
 	return &Error{
 		Message: "request failed",
@@ -979,17 +1341,84 @@ func wrapRequestError(err error) error {
 	}
 }
 
-func errNonOKResponse(code int) error {
-	switch code {
-	case http.StatusBadRequest:
-		return ErrBadRequest
-	case http.StatusUnauthorized:
-		return ErrUnauthorized
-	case http.StatusForbidden:
-		return ErrForbidden
-	case http.StatusNotFound:
-		return ErrNotFound
+func errNonOKResponse(resp *http.Response) error {
+	var kind *Error
+	switch code := resp.StatusCode; {
+	case code == http.StatusBadRequest, code == http.StatusUnprocessableEntity:
+		kind = ErrBadRequest
+	case code == http.StatusUnauthorized:
+		kind = ErrUnauthorized
+	case code == http.StatusForbidden:
+		kind = ErrForbidden
+	case code == http.StatusNotFound:
+		kind = ErrNotFound
+	case code == http.StatusTooManyRequests:
+		kind = ErrTooManyRequests
+	case code >= 500:
+		kind = ErrServer
 	default:
-		return ErrUnknown
+		kind = ErrUnknown
+	}
+
+	e := &Error{
+		Message:    kind.Message,
+		StatusCode: resp.StatusCode,
+		kind:       kind,
 	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	decodeErrorBody(e, data)
+	return e
+}
+
+// decodeErrorBody fills e with the details of a Wallex error response.
+// Malformed or unexpected bodies are ignored.
+func decodeErrorBody(e *Error, data []byte) {
+	body := struct {
+		Code    json.RawMessage `json:"code"`
+		Message string          `json:"message"`
+		Result  json.RawMessage `json:"result"`
+		Errors  json.RawMessage `json:"errors"`
+	}{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return
+	}
+
+	e.ServerMessage = body.Message
+	var code string
+	if err := json.Unmarshal(body.Code, &code); err == nil {
+		e.Code = code
+	} else if len(body.Code) > 0 && string(body.Code) != "null" {
+		e.Code = string(body.Code)
+	}
+
+	e.Fields = decodeErrorFields(body.Errors)
+	if e.Fields == nil {
+		e.Fields = decodeErrorFields(body.Result)
+	}
+}
+
+// decodeErrorFields decodes per-field validation errors, where each field
+// maps to either a message or a list of messages.
+func decodeErrorFields(data json.RawMessage) map[string][]string {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+
+	fields := map[string][]string{}
+	for f, v := range raw {
+		var msgs []string
+		if err := json.Unmarshal(v, &msgs); err == nil {
+			fields[f] = msgs
+			continue
+		}
+		var msg string
+		if err := json.Unmarshal(v, &msg); err == nil {
+			fields[f] = []string{msg}
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
 }
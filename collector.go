@@ -0,0 +1,341 @@
+package wallex
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TradeSink persists trades collected by a TradeCollector.
+type TradeSink interface {
+	// WriteTrades writes new trades of symbol, sorted by time. If it fails,
+	// the same trades are offered again on the next poll.
+	WriteTrades(ctx context.Context, symbol string, trades []*MarketTrade) error
+}
+
+// TradeSinkFunc is an adapter to use a function as a TradeSink.
+type TradeSinkFunc func(ctx context.Context, symbol string, trades []*MarketTrade) error
+
+// WriteTrades calls f(ctx, symbol, trades).
+func (f TradeSinkFunc) WriteTrades(ctx context.Context, symbol string, trades []*MarketTrade) error {
+	return f(ctx, symbol, trades)
+}
+
+// TradeGap is an interval in which trades of a market may have been missed,
+// because two consecutive polls did not overlap.
+type TradeGap struct {
+	Symbol string
+	From   time.Time // Time of the last trade collected before the gap.
+	To     time.Time // Time of the first trade collected after the gap.
+}
+
+func (g *TradeGap) String() string {
+	return fmt.Sprintf("%s trades may be missing between %s and %s",
+		g.Symbol, g.From.Format(time.RFC3339), g.To.Format(time.RFC3339))
+}
+
+// CollectorOptions customizes a TradeCollector.
+type CollectorOptions struct {
+
+	// Symbols are the markets whose trades are collected.
+	Symbols []string
+
+	// Interval is the delay between polls. It should be short enough for
+	// consecutive windows of latest trades to overlap. If zero, it defaults
+	// to 5s.
+	Interval time.Duration
+
+	// Sink, if not nil, receives new trades instead of the Trades channel.
+	Sink TradeSink
+
+	// OnGap, if not nil, is called when trades may have been missed.
+	OnGap func(*TradeGap)
+
+	// OnError, if not nil, is called with every failed poll or write, and
+	// with every dropped late trade as an error that matches ErrLateTrade.
+	OnError func(error)
+
+	// MinBackoff is the delay before polling again after a failure.
+	// It doubles with each consecutive failure up to MaxBackoff.
+	// They default to Interval and 12 times Interval.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Buffer is the capacity of the Trades channel. If zero, it defaults
+	// to 1024.
+	Buffer int
+}
+
+// ErrLateTrade is reported through CollectorOptions.OnError for a trade that
+// showed up behind trades already delivered, and so was dropped.
+var ErrLateTrade = &Error{Message: "late trade"}
+
+// TradeCollector continuously polls the latest trades of markets and
+// delivers every trade once, in time order. Trades have no IDs, so
+// overlapping windows are deduplicated by their time, price, quantity, sum
+// and side; identical trades at the same time are counted rather than
+// collapsed.
+//
+// Polling cannot guarantee that no trade is missed: when consecutive
+// windows do not overlap, the possible gap is reported through OnGap. To
+// keep the output in order, trades at the newest time of a window are held
+// back until the next poll, in case more trades at that time show up. A
+// trade that shows up behind trades already delivered is dropped and
+// reported through OnError.
+type TradeCollector struct {
+	client *Client
+	opt    CollectorOptions
+	trades chan *MarketTrade
+	state  map[string]*collectorState
+}
+
+// collectorState is what a collector knows about the trades of a market.
+type collectorState struct {
+	// seen counts the fingerprints of collected trades that may appear
+	// in the next window.
+	seen map[tradeFingerprint]int
+
+	// pending are the trades at the newest time of the last window,
+	// held back until the next poll.
+	pending []*MarketTrade
+
+	last   time.Time // Time of the last delivered trade.
+	latest time.Time // Time of the latest collected trade.
+}
+
+type tradeFingerprint struct {
+	time     int64
+	price    string
+	quantity string
+	sum      string
+	buy      bool
+}
+
+func fingerprint(t *MarketTrade) tradeFingerprint {
+	return tradeFingerprint{
+		time:     t.Timestamp.UnixNano(),
+		price:    string(t.Price),
+		quantity: string(t.Quantity),
+		sum:      string(t.Sum),
+		buy:      t.IsBuyOrder,
+	}
+}
+
+// NewTradeCollector returns a collector of the trades of c.
+// Call Run to start it.
+func NewTradeCollector(c *Client, opt CollectorOptions) *TradeCollector {
+	if opt.Interval <= 0 {
+		opt.Interval = 5 * time.Second
+	}
+	if opt.MinBackoff <= 0 {
+		opt.MinBackoff = opt.Interval
+	}
+	if opt.MaxBackoff <= 0 {
+		opt.MaxBackoff = 12 * opt.Interval
+	}
+	if opt.Buffer <= 0 {
+		opt.Buffer = 1024
+	}
+	tc := &TradeCollector{
+		client: c,
+		opt:    opt,
+		state:  map[string]*collectorState{},
+	}
+	if opt.Sink == nil {
+		tc.trades = make(chan *MarketTrade, opt.Buffer)
+	}
+	return tc
+}
+
+// Trades returns the channel new trades are delivered on, sorted by time
+// per market. It is closed when Run returns. It is nil if the collector
+// has a Sink.
+func (tc *TradeCollector) Trades() <-chan *MarketTrade {
+	return tc.trades
+}
+
+// Run polls until ctx is done, then closes the Trades channel and returns
+// ctx.Err(). Trades of the first poll are delivered too. Trades held back by
+// the last poll before ctx is done are not delivered. Run must be called at
+// most once.
+func (tc *TradeCollector) Run(ctx context.Context) error {
+	if tc.trades != nil {
+		defer close(tc.trades)
+	}
+
+	backoff := &RetryPolicy{
+		MinBackoff: tc.opt.MinBackoff,
+		MaxBackoff: tc.opt.MaxBackoff,
+		Jitter:     0.2,
+	}
+	failures := 0
+	for {
+		failed := false
+		for _, symbol := range tc.opt.Symbols {
+			if err := tc.poll(ctx, symbol); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if tc.opt.OnError != nil {
+					tc.opt.OnError(err)
+				}
+				failed = true
+			}
+		}
+
+		delay := tc.opt.Interval
+		if failed {
+			failures++
+			delay = backoff.backoff(failures)
+		} else {
+			failures = 0
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (tc *TradeCollector) poll(ctx context.Context, symbol string) error {
+	window, err := tc.client.MarketTradesContext(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	st := tc.state[symbol]
+	if st == nil {
+		st = &collectorState{seen: map[tradeFingerprint]int{}}
+	}
+
+	trades, seen, gap := collect(st, window)
+	for _, t := range trades {
+		if t.Symbol == "" {
+			t.Symbol = symbol
+		}
+	}
+
+	var newest time.Time
+	for _, t := range window {
+		if t.Timestamp.After(newest) {
+			newest = t.Timestamp
+		}
+	}
+
+	// Trades behind the last delivered one cannot be delivered in order.
+	// Trades held back by the previous poll are ready, and so are new ones
+	// older than the newest of the window.
+	var late, pending []*MarketTrade
+	ready := append([]*MarketTrade(nil), st.pending...)
+	for _, t := range trades {
+		switch {
+		case t.Timestamp.Before(st.last):
+			late = append(late, t)
+		case t.Timestamp.Equal(newest):
+			pending = append(pending, t)
+		default:
+			ready = append(ready, t)
+		}
+	}
+	sort.SliceStable(ready, func(i, j int) bool {
+		return ready[i].Timestamp.Before(ready[j].Timestamp)
+	})
+
+	if len(ready) > 0 && tc.opt.Sink != nil {
+		if err := tc.opt.Sink.WriteTrades(ctx, symbol, ready); err != nil {
+			// Keep the old state, so that the trades are offered again.
+			return err
+		}
+	}
+
+	if gap && tc.opt.OnGap != nil {
+		tc.opt.OnGap(&TradeGap{Symbol: symbol, From: st.latest, To: trades[0].Timestamp})
+	}
+	if tc.opt.OnError != nil {
+		for _, t := range late {
+			tc.opt.OnError(&Error{
+				Message: fmt.Sprintf("dropped %s trade at %s behind trades already delivered",
+					symbol, t.Timestamp.Format(time.RFC3339Nano)),
+				kind: ErrLateTrade,
+			})
+		}
+	}
+	if len(ready) > 0 {
+		st.last = ready[len(ready)-1].Timestamp
+	}
+	if len(trades) > 0 && trades[len(trades)-1].Timestamp.After(st.latest) {
+		st.latest = trades[len(trades)-1].Timestamp
+	}
+	st.seen = seen
+	st.pending = pending
+	tc.state[symbol] = st
+
+	if tc.trades != nil {
+		for _, t := range ready {
+			select {
+			case tc.trades <- t:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// collect returns the trades of window not collected yet, sorted by time,
+// and the fingerprints to remember for the next window. It reports a gap if
+// trades were collected before, yet the window does not overlap them.
+func collect(st *collectorState, window []*MarketTrade) (trades []*MarketTrade, seen map[tradeFingerprint]int, gap bool) {
+	sorted := append([]*MarketTrade(nil), window...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	// A trade repeated n times in the window is new only if fewer than n
+	// of it were collected before.
+	counts := map[tradeFingerprint]int{}
+	for _, t := range sorted {
+		counts[fingerprint(t)]++
+	}
+	remaining := make(map[tradeFingerprint]int, len(counts))
+	for fp, n := range counts {
+		if old := st.seen[fp]; n > old {
+			remaining[fp] = n - old
+		}
+	}
+	// Trades older than the window will never be seen again.
+	overlap := false
+	seen = map[tradeFingerprint]int{}
+	var oldest int64
+	if len(sorted) > 0 {
+		oldest = sorted[0].Timestamp.UnixNano()
+	}
+	for fp, n := range st.seen {
+		if fp.time >= oldest {
+			seen[fp] = n
+		}
+	}
+	for fp, n := range counts {
+		if n > seen[fp] {
+			seen[fp] = n
+		}
+		if st.seen[fp] > 0 {
+			overlap = true
+		}
+	}
+
+	// Later duplicates are the new ones, so that the delivered trades stay
+	// in order after the ones collected before.
+	for i := len(sorted) - 1; i >= 0; i-- {
+		fp := fingerprint(sorted[i])
+		if remaining[fp] > 0 {
+			remaining[fp]--
+			trades = append(trades, sorted[i])
+		}
+	}
+	for i, j := 0, len(trades)-1; i < j; i, j = i+1, j-1 {
+		trades[i], trades[j] = trades[j], trades[i]
+	}
+
+	gap = len(st.seen) > 0 && !overlap && len(trades) > 0
+	return trades, seen, gap
+}
@@ -0,0 +1,208 @@
+package wallex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+
+// trade returns a buy trade sec seconds after epoch.
+func trade(sec int, price Number) *MarketTrade {
+	return &MarketTrade{
+		Symbol:     "BTCTMN",
+		Timestamp:  epoch.Add(time.Duration(sec) * time.Second),
+		Price:      price,
+		Quantity:   "1",
+		Sum:        price,
+		IsBuyOrder: true,
+	}
+}
+
+// prices returns the prices of trades, to compare them in tests.
+func prices(trades []*MarketTrade) []Number {
+	var ps []Number
+	for _, t := range trades {
+		ps = append(ps, t.Price)
+	}
+	return ps
+}
+
+// collectAll runs collect on windows in turn and returns the prices of the
+// new trades of each window and whether it reported a gap.
+func collectAll(windows ...[]*MarketTrade) ([][]Number, []bool) {
+	st := &collectorState{seen: map[tradeFingerprint]int{}}
+	var news [][]Number
+	var gaps []bool
+	for _, w := range windows {
+		trades, seen, gap := collect(st, w)
+		st.seen = seen
+		news = append(news, prices(trades))
+		gaps = append(gaps, gap)
+	}
+	return news, gaps
+}
+
+func TestCollectOverlappingWindows(t *testing.T) {
+	news, gaps := collectAll(
+		[]*MarketTrade{trade(3, "3"), trade(1, "1"), trade(2, "2")},
+		[]*MarketTrade{trade(2, "2"), trade(3, "3"), trade(4, "4"), trade(5, "5")},
+		[]*MarketTrade{trade(4, "4"), trade(5, "5")},
+		[]*MarketTrade{trade(5, "5"), trade(5, "5.1"), trade(6, "6")},
+	)
+	want := [][]Number{{"1", "2", "3"}, {"4", "5"}, nil, {"5.1", "6"}}
+	if !reflect.DeepEqual(news, want) {
+		t.Errorf("new trades = %v, want %v", news, want)
+	}
+	if !reflect.DeepEqual(gaps, []bool{false, false, false, false}) {
+		t.Errorf("gaps = %v, want none", gaps)
+	}
+}
+
+func TestCollectRepeatedTrades(t *testing.T) {
+	a := func() *MarketTrade { return trade(1, "1") }
+	news, gaps := collectAll(
+		[]*MarketTrade{a(), a()},
+		[]*MarketTrade{a(), a(), a()},
+		[]*MarketTrade{a(), a(), a()},
+		[]*MarketTrade{a(), trade(2, "2")},
+	)
+	want := [][]Number{{"1", "1"}, {"1"}, nil, {"2"}}
+	if !reflect.DeepEqual(news, want) {
+		t.Errorf("new trades = %v, want %v", news, want)
+	}
+	if !reflect.DeepEqual(gaps, []bool{false, false, false, false}) {
+		t.Errorf("gaps = %v, want none", gaps)
+	}
+}
+
+func TestCollectGap(t *testing.T) {
+	news, gaps := collectAll(
+		nil,
+		[]*MarketTrade{trade(1, "1"), trade(2, "2")},
+		[]*MarketTrade{trade(5, "5"), trade(6, "6")},
+		[]*MarketTrade{trade(6, "6")},
+		nil,
+	)
+	want := [][]Number{nil, {"1", "2"}, {"5", "6"}, nil, nil}
+	if !reflect.DeepEqual(news, want) {
+		t.Errorf("new trades = %v, want %v", news, want)
+	}
+	if !reflect.DeepEqual(gaps, []bool{false, false, true, false, false}) {
+		t.Errorf("gaps = %v, want only the third window", gaps)
+	}
+}
+
+// tradesServer serves the latest trades from a window that tests replace.
+type tradesServer struct {
+	mu     sync.Mutex
+	window []*MarketTrade
+}
+
+func (s *tradesServer) set(window ...*MarketTrade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.window = window
+}
+
+func newCollector(t *testing.T, opt CollectorOptions) (*TradeCollector, *tradesServer) {
+	ts := &tradesServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"result":  map[string]interface{}{"latestTrades": ts.window},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	c := New(ClientOptions{Environment: LocalEnvironment(srv.URL)})
+	return NewTradeCollector(c, opt), ts
+}
+
+func TestCollectorDelivery(t *testing.T) {
+	var delivered []Number
+	var errs []error
+	var gaps []*TradeGap
+	tc, ts := newCollector(t, CollectorOptions{
+		Sink: TradeSinkFunc(func(ctx context.Context, symbol string, trades []*MarketTrade) error {
+			delivered = append(delivered, prices(trades)...)
+			return nil
+		}),
+		OnError: func(err error) { errs = append(errs, err) },
+		OnGap:   func(g *TradeGap) { gaps = append(gaps, g) },
+	})
+	ctx := context.Background()
+
+	steps := []struct {
+		window []*MarketTrade
+		want   []Number
+	}{
+		// The newest trade is held back for a poll in case of ties.
+		{[]*MarketTrade{trade(1, "1"), trade(2, "2"), trade(3, "3")}, []Number{"1", "2"}},
+		{[]*MarketTrade{trade(2, "2"), trade(3, "3"), trade(3, "3.1")}, []Number{"1", "2", "3"}},
+		{[]*MarketTrade{trade(3, "3"), trade(3, "3.1"), trade(4, "4")}, []Number{"1", "2", "3", "3.1"}},
+		// Nothing new still releases the held trade.
+		{[]*MarketTrade{trade(3, "3"), trade(3, "3.1"), trade(4, "4")}, []Number{"1", "2", "3", "3.1", "4"}},
+		// A trade behind delivered ones is dropped.
+		{[]*MarketTrade{trade(2, "2.5"), trade(4, "4"), trade(5, "5"), trade(6, "6")}, []Number{"1", "2", "3", "3.1", "4", "5"}},
+		// A window that does not overlap is a gap.
+		{[]*MarketTrade{trade(20, "20"), trade(21, "21")}, []Number{"1", "2", "3", "3.1", "4", "5", "6", "20"}},
+	}
+	for i, step := range steps {
+		ts.set(step.window...)
+		if err := tc.poll(ctx, "BTCTMN"); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(delivered, step.want) {
+			t.Fatalf("step %d: delivered %v, want %v", i, delivered, step.want)
+		}
+	}
+
+	if len(errs) != 1 || !errors.Is(errs[0], ErrLateTrade) {
+		t.Errorf("errors = %v, want one late trade", errs)
+	}
+	if len(gaps) != 1 || !gaps[0].From.Equal(epoch.Add(6*time.Second)) || !gaps[0].To.Equal(epoch.Add(20*time.Second)) {
+		t.Errorf("gaps = %v, want one from 6s to 20s", gaps)
+	}
+}
+
+func TestCollectorSinkErrorOffersTradesAgain(t *testing.T) {
+	var calls [][]Number
+	fail := true
+	tc, ts := newCollector(t, CollectorOptions{
+		Sink: TradeSinkFunc(func(ctx context.Context, symbol string, trades []*MarketTrade) error {
+			calls = append(calls, prices(trades))
+			if fail {
+				return errors.New("disk full")
+			}
+			return nil
+		}),
+	})
+	ctx := context.Background()
+
+	ts.set(trade(1, "1"), trade(2, "2"), trade(3, "3"))
+	if err := tc.poll(ctx, "BTCTMN"); err == nil {
+		t.Fatal("failed write is not reported")
+	}
+	fail = false
+	if err := tc.poll(ctx, "BTCTMN"); err != nil {
+		t.Fatal(err)
+	}
+	ts.set(trade(3, "3"), trade(4, "4"))
+	if err := tc.poll(ctx, "BTCTMN"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]Number{{"1", "2"}, {"1", "2"}, {"3"}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("writes = %v, want %v", calls, want)
+	}
+}
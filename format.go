@@ -0,0 +1,199 @@
+package wallex
+
+import (
+	"strings"
+)
+
+// Locale is the language numbers are formatted in.
+type Locale int
+
+// List of supported locales.
+const (
+	LocaleEn Locale = iota // English digits and separators.
+	LocaleFa               // Persian digits and separators.
+)
+
+// Asset labels of Iranian currencies.
+const (
+	Toman = "TMN"
+	Rial  = "IRR"
+
+	FarsiToman = "تومان"
+	FarsiRial  = "ریال"
+)
+
+// FormatOptions customizes human-readable formatting of a Number.
+type FormatOptions struct {
+	Locale Locale
+
+	// Precision is the number of fractional digits.
+	// If negative, the precision of the number is kept.
+	Precision int
+
+	// Mode is used to round the number to Precision.
+	Mode RoundingMode
+
+	// Grouping separates thousands in the integer part.
+	Grouping bool
+
+	// Unit is appended to the number, separated by a space.
+	Unit string
+}
+
+// Humanize formats n for display, e.g. "12,345.60 TMN" or "۱۲٬۳۴۵٫۶۰ تومان".
+// It returns an empty string if n is not a valid number.
+func (n Number) Humanize(opt FormatOptions) string {
+	if opt.Precision < 0 {
+		opt.Precision = n.scale()
+	}
+	s := string(n.Round(opt.Precision, opt.Mode))
+	if s == "" {
+		return ""
+	}
+
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+
+	thousands, decimal := ",", "."
+	if opt.Locale == LocaleFa {
+		thousands, decimal = "٬", "٫"
+	}
+
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	for i, d := range intPart {
+		if opt.Grouping && i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(thousands)
+		}
+		b.WriteRune(d)
+	}
+	if fracPart != "" {
+		b.WriteString(decimal)
+		b.WriteString(fracPart)
+	}
+	s = b.String()
+	if opt.Locale == LocaleFa {
+		// Only the number is transliterated, not digits of the unit,
+		// e.g. of "1INCH".
+		s = PersianDigits(s)
+	}
+	if opt.Unit != "" {
+		s += " " + opt.Unit
+	}
+	return s
+}
+
+// scale returns the number of fractional digits n is written with.
+func (n Number) scale() int {
+	s := strings.TrimLeft(string(n), "+-")
+	exp := 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		r, ok := Number(s[i+1:]).Rat()
+		if ok && r.IsInt() {
+			exp = int(r.Num().Int64())
+		}
+		s = s[:i]
+	}
+	frac := 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		frac = len(s) - i - 1
+	}
+	if frac -= exp; frac < 0 {
+		return 0
+	}
+	return frac
+}
+
+// PersianDigits replaces ASCII digits in s with Persian digits.
+func PersianDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if '0' <= r && r <= '9' {
+			return '۰' + r - '0'
+		}
+		return r
+	}, s)
+}
+
+// TomanToRial converts an amount in Toman to Rial.
+func TomanToRial(n Number) Number {
+	return n.Mul("10")
+}
+
+// RialToToman converts an amount in Rial to Toman.
+func RialToToman(n Number) Number {
+	return n.Mul("0.1")
+}
+
+// AssetLabel returns the label of asset in locale l.
+// The label of Toman and Rial are localized, while others are kept as is.
+func AssetLabel(asset string, l Locale) string {
+	if l == LocaleFa {
+		switch asset {
+		case Toman:
+			return FarsiToman
+		case Rial:
+			return FarsiRial
+		}
+	}
+	return asset
+}
+
+// BaseLabel returns the label of the base asset of m in locale l.
+func (m *Market) BaseLabel(l Locale) string {
+	if l == LocaleFa && m.FarsiBaseAsset != "" {
+		return m.FarsiBaseAsset
+	}
+	return AssetLabel(m.BaseAsset, l)
+}
+
+// QuoteLabel returns the label of the quote asset of m in locale l.
+func (m *Market) QuoteLabel(l Locale) string {
+	if l == LocaleFa && m.FarsiQuoteAsset != "" {
+		return m.FarsiQuoteAsset
+	}
+	return AssetLabel(m.QuoteAsset, l)
+}
+
+// FormatPrice formats p with the price precision and quote asset of m.
+func (m *Market) FormatPrice(p Number, l Locale) string {
+	return p.Humanize(FormatOptions{
+		Locale:    l,
+		Precision: m.PricePrecision(),
+		Grouping:  true,
+		Unit:      m.QuoteLabel(l),
+	})
+}
+
+// FormatQuantity formats q with the quantity precision and base asset of m.
+func (m *Market) FormatQuantity(q Number, l Locale) string {
+	return q.Humanize(FormatOptions{
+		Locale:    l,
+		Precision: m.QuantityPrecision(),
+		Grouping:  true,
+		Unit:      m.BaseLabel(l),
+	})
+}
+
+// Label returns the label of the asset of b in locale l.
+func (b *Balance) Label(l Locale) string {
+	if l == LocaleFa && b.FaName != "" {
+		return b.FaName
+	}
+	return AssetLabel(b.Asset, l)
+}
+
+// FormatValue formats the value of b with its asset label.
+func (b *Balance) FormatValue(l Locale) string {
+	return b.Value.Humanize(FormatOptions{
+		Locale:    l,
+		Precision: -1,
+		Grouping:  true,
+		Unit:      b.Label(l),
+	})
+}
@@ -0,0 +1,218 @@
+// Package indicators computes technical indicators over candles.
+//
+// Every indicator comes in two forms: a function that computes it over a
+// series of candles, e.g. SMA, and a stream that is updated one candle at a
+// time as new candles arrive, e.g. SMAStream. Both compute the same values.
+//
+// Values are float64 and NaN until an indicator has seen enough candles,
+// so that the result of a function is aligned with its input candles.
+// Streams must be updated once per closed candle, in time order.
+package indicators
+
+import (
+	"fmt"
+	"math"
+
+	wallex "github.com/wallexchange/wallex-go"
+)
+
+var nan = math.NaN()
+
+func checkPeriod(name string, period int) {
+	if period < 1 {
+		panic(fmt.Sprintf("indicators: %s period must be positive, got %d", name, period))
+	}
+}
+
+// window is a ring buffer of the last values of a series.
+type window struct {
+	values []float64
+	next   int
+	full   bool
+}
+
+func newWindow(n int) window {
+	return window{values: make([]float64, n)}
+}
+
+// push appends v, evicting and returning the oldest value if the window
+// was full.
+func (w *window) push(v float64) (old float64, evicted bool) {
+	old, evicted = w.values[w.next], w.full
+	w.values[w.next] = v
+	w.next++
+	if w.next == len(w.values) {
+		w.next = 0
+		w.full = true
+	}
+	return old, evicted
+}
+
+// each calls f with the values of w, oldest first.
+func (w *window) each(f func(v float64)) {
+	if w.full {
+		for _, v := range w.values[w.next:] {
+			f(v)
+		}
+	}
+	for _, v := range w.values[:w.next] {
+		f(v)
+	}
+}
+
+func (w *window) sum() float64 {
+	var s float64
+	w.each(func(v float64) { s += v })
+	return s
+}
+
+// -----------------------------------------------------------------------------
+// Moving averages
+// -----------------------------------------------------------------------------
+
+// SMA returns the simple moving average of the closes of candles.
+func SMA(candles []*wallex.Candle, period int) []float64 {
+	return closeSeries(candles, NewSMAStream(period))
+}
+
+// EMA returns the exponential moving average of the closes of candles.
+func EMA(candles []*wallex.Candle, period int) []float64 {
+	return closeSeries(candles, NewEMAStream(period))
+}
+
+// WMA returns the linearly weighted moving average of the closes of candles.
+func WMA(candles []*wallex.Candle, period int) []float64 {
+	return closeSeries(candles, NewWMAStream(period))
+}
+
+// closeSeries feeds the closes of candles to a moving average.
+func closeSeries(candles []*wallex.Candle, ma interface{ Add(float64) float64 }) []float64 {
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		out[i] = ma.Add(c.Close.Float())
+	}
+	return out
+}
+
+// SMAStream is a simple moving average updated incrementally.
+type SMAStream struct {
+	w     window
+	total float64
+	value float64
+}
+
+// NewSMAStream returns a simple moving average of period values.
+func NewSMAStream(period int) *SMAStream {
+	checkPeriod("SMA", period)
+	return &SMAStream{w: newWindow(period), value: nan}
+}
+
+// Update adds the close of c and returns the new average.
+func (s *SMAStream) Update(c *wallex.Candle) float64 {
+	return s.Add(c.Close.Float())
+}
+
+// Add adds v and returns the new average.
+func (s *SMAStream) Add(v float64) float64 {
+	old, evicted := s.w.push(v)
+	s.total += v
+	if evicted {
+		s.total -= old
+	}
+	if s.w.next == 0 {
+		// Start over once per period, so that rounding errors of the
+		// running total do not accumulate.
+		s.total = s.w.sum()
+	}
+	if s.w.full {
+		s.value = s.total / float64(len(s.w.values))
+	}
+	return s.value
+}
+
+// Value returns the current average.
+func (s *SMAStream) Value() float64 { return s.value }
+
+// Ready reports whether enough values were added for an average.
+func (s *SMAStream) Ready() bool { return s.w.full }
+
+// EMAStream is an exponential moving average updated incrementally.
+// It is seeded with the simple average of its first period values.
+type EMAStream struct {
+	period int
+	alpha  float64
+	n      int
+	seed   float64 // Sum of the first values, before value is defined.
+	value  float64
+}
+
+// NewEMAStream returns an exponential moving average of period values,
+// with a smoothing factor of 2/(period+1).
+func NewEMAStream(period int) *EMAStream {
+	checkPeriod("EMA", period)
+	return &EMAStream{period: period, alpha: 2 / float64(period+1), value: nan}
+}
+
+// Update adds the close of c and returns the new average.
+func (s *EMAStream) Update(c *wallex.Candle) float64 {
+	return s.Add(c.Close.Float())
+}
+
+// Add adds v and returns the new average.
+func (s *EMAStream) Add(v float64) float64 {
+	s.n++
+	switch {
+	case s.n < s.period:
+		s.seed += v
+	case s.n == s.period:
+		s.value = (s.seed + v) / float64(s.period)
+	default:
+		s.value += s.alpha * (v - s.value)
+	}
+	return s.value
+}
+
+// Value returns the current average.
+func (s *EMAStream) Value() float64 { return s.value }
+
+// Ready reports whether enough values were added for an average.
+func (s *EMAStream) Ready() bool { return s.n >= s.period }
+
+// WMAStream is a linearly weighted moving average updated incrementally.
+// The latest value has weight period and the oldest has weight 1.
+type WMAStream struct {
+	w     window
+	value float64
+}
+
+// NewWMAStream returns a weighted moving average of period values.
+func NewWMAStream(period int) *WMAStream {
+	checkPeriod("WMA", period)
+	return &WMAStream{w: newWindow(period), value: nan}
+}
+
+// Update adds the close of c and returns the new average.
+func (s *WMAStream) Update(c *wallex.Candle) float64 {
+	return s.Add(c.Close.Float())
+}
+
+// Add adds v and returns the new average.
+func (s *WMAStream) Add(v float64) float64 {
+	s.w.push(v)
+	if !s.w.full {
+		return s.value
+	}
+	var total, weight float64
+	s.w.each(func(v float64) {
+		weight++
+		total += weight * v
+	})
+	s.value = total / (weight * (weight + 1) / 2)
+	return s.value
+}
+
+// Value returns the current average.
+func (s *WMAStream) Value() float64 { return s.value }
+
+// Ready reports whether enough values were added for an average.
+func (s *WMAStream) Ready() bool { return s.w.full }
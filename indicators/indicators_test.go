@@ -0,0 +1,209 @@
+package indicators_test
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/indicators"
+)
+
+var start = time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+func number(v float64) wallex.Number {
+	return wallex.Number(strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+// candle returns an hourly candle i hours after start.
+func candle(i int, high, low, cl, volume float64) *wallex.Candle {
+	return &wallex.Candle{
+		Timestamp: start.Add(time.Duration(i) * time.Hour),
+		Open:      number(cl),
+		High:      number(high),
+		Low:       number(low),
+		Close:     number(cl),
+		Volume:    number(volume),
+	}
+}
+
+// closes returns candles with the given closes and no range.
+func closes(vs ...float64) []*wallex.Candle {
+	candles := make([]*wallex.Candle, len(vs))
+	for i, v := range vs {
+		candles[i] = candle(i, v, v, v, 1)
+	}
+	return candles
+}
+
+// randomCandles returns a reproducible random walk of n candles.
+func randomCandles(n int) []*wallex.Candle {
+	r := rand.New(rand.NewSource(1))
+	candles := make([]*wallex.Candle, n)
+	price := 1000.0
+	for i := range candles {
+		price += r.NormFloat64() * 10
+		high := price + r.Float64()*5
+		low := price - r.Float64()*5
+		candles[i] = candle(i, high, low, price, math.Floor(r.Float64()*100))
+	}
+	return candles
+}
+
+// near reports whether got is within 1e-9 of want, or both are NaN.
+func near(got, want float64) bool {
+	if math.IsNaN(want) {
+		return math.IsNaN(got)
+	}
+	return math.Abs(got-want) < 1e-9
+}
+
+// same reports whether a and b are identical, treating NaNs as equal.
+func same(a, b float64) bool {
+	return a == b || math.IsNaN(a) && math.IsNaN(b)
+}
+
+func checkSeries(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s returned %d values, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if !near(got[i], want[i]) {
+			t.Errorf("%s[%d] = %v, want %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+var nan = math.NaN()
+
+func TestSMA(t *testing.T) {
+	got := indicators.SMA(closes(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 3)
+	checkSeries(t, "SMA", got, []float64{nan, nan, 2, 3, 4, 5, 6, 7, 8, 9})
+}
+
+func TestEMA(t *testing.T) {
+	// Seeded with the SMA of the first 3 closes, then smoothed by 2/(3+1).
+	got := indicators.EMA(closes(2, 4, 6, 8, 4, 4), 3)
+	checkSeries(t, "EMA", got, []float64{nan, nan, 4, 6, 5, 4.5})
+}
+
+func TestWMA(t *testing.T) {
+	got := indicators.WMA(closes(1, 2, 3, 6), 3)
+	checkSeries(t, "WMA", got, []float64{nan, nan, 14.0 / 6, 26.0 / 6})
+}
+
+func TestSMAPrecision(t *testing.T) {
+	// The running total starts over once per period, so a huge value
+	// leaves no trace once it is out of the window.
+	got := indicators.SMA(closes(1e17, 1, 1, 0.1, 0.2, 0.3), 3)
+	if last := got[len(got)-1]; !near(last, 0.2) {
+		t.Errorf("SMA after a huge value = %v, want 0.2", last)
+	}
+}
+
+func TestStreamReady(t *testing.T) {
+	s := indicators.NewSMAStream(2)
+	if s.Ready() || !math.IsNaN(s.Value()) {
+		t.Errorf("new SMAStream: Ready = %v, Value = %v", s.Ready(), s.Value())
+	}
+	s.Add(1)
+	if s.Ready() {
+		t.Error("SMAStream is ready after 1 of 2 values")
+	}
+	s.Add(2)
+	if !s.Ready() || s.Value() != 1.5 {
+		t.Errorf("SMAStream after 2 values: Ready = %v, Value = %v", s.Ready(), s.Value())
+	}
+}
+
+// TestStreamsMatchFunctions checks that updating a stream one candle at a
+// time gives the same values as the function over all candles.
+func TestStreamsMatchFunctions(t *testing.T) {
+	candles := randomCandles(300)
+	tehran := time.FixedZone("+0330", 3*60*60+30*60)
+
+	type updater interface {
+		Update(*wallex.Candle) float64
+	}
+	type adder interface {
+		Add(float64) float64
+	}
+	floats := []struct {
+		name   string
+		series []float64
+		stream func() updater
+	}{
+		{"SMA", indicators.SMA(candles, 20), func() updater { return indicators.NewSMAStream(20) }},
+		{"EMA", indicators.EMA(candles, 20), func() updater { return indicators.NewEMAStream(20) }},
+		{"WMA", indicators.WMA(candles, 20), func() updater { return indicators.NewWMAStream(20) }},
+		{"RSI", indicators.RSI(candles, 14), func() updater { return indicators.NewRSIStream(14) }},
+		{"ATR", indicators.ATR(candles, 14), func() updater { return indicators.NewATRStream(14) }},
+		{"OBV", indicators.OBV(candles), func() updater { return indicators.NewOBVStream() }},
+		{"VWAP", indicators.VWAP(candles, tehran), func() updater { return indicators.NewVWAPStream(tehran) }},
+	}
+	for _, tt := range floats {
+		s := tt.stream()
+		a, _ := tt.stream().(adder)
+		for i, c := range candles {
+			if got := s.Update(c); !same(got, tt.series[i]) {
+				t.Errorf("%sStream.Update at %d = %v, want %v", tt.name, i, got, tt.series[i])
+			}
+			if a == nil {
+				continue
+			}
+			if got := a.Add(c.Close.Float()); !same(got, tt.series[i]) {
+				t.Errorf("%sStream.Add at %d = %v, want %v", tt.name, i, got, tt.series[i])
+			}
+		}
+	}
+
+	macd := indicators.MACD(candles, 12, 26, 9)
+	ms := indicators.NewMACDStream(12, 26, 9)
+	bands := indicators.Bollinger(candles, 20, 2)
+	bs := indicators.NewBollingerStream(20, 2)
+	stoch := indicators.Stochastic(candles, 14, 3)
+	ss := indicators.NewStochasticStream(14, 3)
+	for i, c := range candles {
+		if got, want := ms.Update(c), macd[i]; !same(got.MACD, want.MACD) || !same(got.Signal, want.Signal) || !same(got.Histogram, want.Histogram) {
+			t.Errorf("MACDStream.Update at %d = %+v, want %+v", i, got, want)
+		}
+		if got, want := bs.Update(c), bands[i]; !same(got.Upper, want.Upper) || !same(got.Middle, want.Middle) || !same(got.Lower, want.Lower) {
+			t.Errorf("BollingerStream.Update at %d = %+v, want %+v", i, got, want)
+		}
+		if got, want := ss.Update(c), stoch[i]; !same(got.K, want.K) || !same(got.D, want.D) {
+			t.Errorf("StochasticStream.Update at %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestInvalidPeriods(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func()
+	}{
+		{"SMA(0)", func() { indicators.NewSMAStream(0) }},
+		{"EMA(-1)", func() { indicators.NewEMAStream(-1) }},
+		{"WMA(0)", func() { indicators.NewWMAStream(0) }},
+		{"RSI(0)", func() { indicators.NewRSIStream(0) }},
+		{"MACD(0, 26, 9)", func() { indicators.NewMACDStream(0, 26, 9) }},
+		{"MACD(12, 26, 0)", func() { indicators.NewMACDStream(12, 26, 0) }},
+		{"MACD(26, 12, 9)", func() { indicators.NewMACDStream(26, 12, 9) }},
+		{"MACD(12, 12, 9)", func() { indicators.NewMACDStream(12, 12, 9) }},
+		{"Stochastic(14, 0)", func() { indicators.NewStochasticStream(14, 0) }},
+		{"Bollinger(0, 2)", func() { indicators.NewBollingerStream(0, 2) }},
+		{"ATR(0)", func() { indicators.NewATRStream(0) }},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s does not panic", tt.name)
+				}
+			}()
+			tt.f()
+		}()
+	}
+}
@@ -0,0 +1,230 @@
+package indicators
+
+import (
+	"fmt"
+	"math"
+
+	wallex "github.com/wallexchange/wallex-go"
+)
+
+// -----------------------------------------------------------------------------
+// RSI
+// -----------------------------------------------------------------------------
+
+// RSI returns the relative strength index of the closes of candles, from 0
+// to 100, with Wilder's smoothing.
+func RSI(candles []*wallex.Candle, period int) []float64 {
+	s := NewRSIStream(period)
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		out[i] = s.Update(c)
+	}
+	return out
+}
+
+// RSIStream is a relative strength index updated incrementally.
+// It needs period+1 closes, i.e. period changes, for its first value.
+type RSIStream struct {
+	period  int
+	prev    float64
+	n       int // Number of closes.
+	avgGain float64
+	avgLoss float64
+	value   float64
+}
+
+// NewRSIStream returns a relative strength index of period changes.
+func NewRSIStream(period int) *RSIStream {
+	checkPeriod("RSI", period)
+	return &RSIStream{period: period, value: nan}
+}
+
+// Update adds the close of c and returns the new index.
+func (s *RSIStream) Update(c *wallex.Candle) float64 {
+	return s.Add(c.Close.Float())
+}
+
+// Add adds the close v and returns the new index.
+func (s *RSIStream) Add(v float64) float64 {
+	s.n++
+	if s.n == 1 {
+		s.prev = v
+		return s.value
+	}
+	gain, loss := 0.0, 0.0
+	if d := v - s.prev; d > 0 {
+		gain = d
+	} else {
+		loss = -d
+	}
+	s.prev = v
+
+	p := float64(s.period)
+	switch changes := s.n - 1; {
+	case changes < s.period:
+		s.avgGain += gain
+		s.avgLoss += loss
+		return s.value
+	case changes == s.period:
+		s.avgGain = (s.avgGain + gain) / p
+		s.avgLoss = (s.avgLoss + loss) / p
+	default:
+		s.avgGain = (s.avgGain*(p-1) + gain) / p
+		s.avgLoss = (s.avgLoss*(p-1) + loss) / p
+	}
+
+	switch {
+	case s.avgLoss == 0 && s.avgGain == 0:
+		s.value = 50
+	case s.avgLoss == 0:
+		s.value = 100
+	default:
+		s.value = 100 - 100/(1+s.avgGain/s.avgLoss)
+	}
+	return s.value
+}
+
+// Value returns the current index.
+func (s *RSIStream) Value() float64 { return s.value }
+
+// Ready reports whether enough closes were added for an index.
+func (s *RSIStream) Ready() bool { return s.n > s.period }
+
+// -----------------------------------------------------------------------------
+// MACD
+// -----------------------------------------------------------------------------
+
+// MACDValue is a value of the moving average convergence divergence.
+type MACDValue struct {
+	MACD      float64 // Fast EMA minus slow EMA.
+	Signal    float64 // EMA of MACD.
+	Histogram float64 // MACD minus Signal.
+}
+
+// MACD returns the moving average convergence divergence of the closes of
+// candles. The usual periods are 12, 26 and 9.
+func MACD(candles []*wallex.Candle, fast, slow, signal int) []MACDValue {
+	s := NewMACDStream(fast, slow, signal)
+	out := make([]MACDValue, len(candles))
+	for i, c := range candles {
+		out[i] = s.Update(c)
+	}
+	return out
+}
+
+// MACDStream is a moving average convergence divergence updated
+// incrementally. MACD is defined once the slow EMA is, and Signal and
+// Histogram signal-1 closes later.
+type MACDStream struct {
+	fast, slow, signal *EMAStream
+	value              MACDValue
+}
+
+// NewMACDStream returns a moving average convergence divergence of EMAs of
+// fast and slow periods, with a signal EMA of signal period. The fast period
+// must be shorter than the slow one.
+func NewMACDStream(fast, slow, signal int) *MACDStream {
+	checkPeriod("MACD fast", fast)
+	checkPeriod("MACD signal", signal)
+	if fast >= slow {
+		panic(fmt.Sprintf("indicators: MACD fast period must be less than slow period, got %d and %d", fast, slow))
+	}
+	return &MACDStream{
+		fast:   NewEMAStream(fast),
+		slow:   NewEMAStream(slow),
+		signal: NewEMAStream(signal),
+		value:  MACDValue{nan, nan, nan},
+	}
+}
+
+// Update adds the close of c and returns the new value.
+func (s *MACDStream) Update(c *wallex.Candle) MACDValue {
+	return s.Add(c.Close.Float())
+}
+
+// Add adds the close v and returns the new value.
+func (s *MACDStream) Add(v float64) MACDValue {
+	fast := s.fast.Add(v)
+	slow := s.slow.Add(v)
+	if !s.fast.Ready() || !s.slow.Ready() {
+		return s.value
+	}
+	s.value.MACD = fast - slow
+	s.value.Signal = s.signal.Add(s.value.MACD)
+	s.value.Histogram = s.value.MACD - s.value.Signal
+	return s.value
+}
+
+// Value returns the current value.
+func (s *MACDStream) Value() MACDValue { return s.value }
+
+// Ready reports whether enough closes were added for all of MACD, Signal
+// and Histogram.
+func (s *MACDStream) Ready() bool { return s.signal.Ready() }
+
+// -----------------------------------------------------------------------------
+// Stochastic
+// -----------------------------------------------------------------------------
+
+// StochasticValue is a value of the stochastic oscillator, from 0 to 100.
+type StochasticValue struct {
+	K float64 // Position of the close within the recent range.
+	D float64 // SMA of K.
+}
+
+// Stochastic returns the stochastic oscillator of candles. The usual
+// periods are 14 and 3.
+func Stochastic(candles []*wallex.Candle, kPeriod, dPeriod int) []StochasticValue {
+	s := NewStochasticStream(kPeriod, dPeriod)
+	out := make([]StochasticValue, len(candles))
+	for i, c := range candles {
+		out[i] = s.Update(c)
+	}
+	return out
+}
+
+// StochasticStream is a stochastic oscillator updated incrementally.
+type StochasticStream struct {
+	high, low window
+	d         *SMAStream
+	value     StochasticValue
+}
+
+// NewStochasticStream returns a stochastic oscillator whose K is over the
+// range of kPeriod candles and whose D is the SMA of dPeriod Ks.
+func NewStochasticStream(kPeriod, dPeriod int) *StochasticStream {
+	checkPeriod("stochastic K", kPeriod)
+	checkPeriod("stochastic D", dPeriod)
+	return &StochasticStream{
+		high:  newWindow(kPeriod),
+		low:   newWindow(kPeriod),
+		d:     NewSMAStream(dPeriod),
+		value: StochasticValue{nan, nan},
+	}
+}
+
+// Update adds c and returns the new value. K is 50 if the range is empty.
+func (s *StochasticStream) Update(c *wallex.Candle) StochasticValue {
+	s.high.push(c.High.Float())
+	s.low.push(c.Low.Float())
+	if !s.high.full {
+		return s.value
+	}
+	high, low := math.Inf(-1), math.Inf(1)
+	s.high.each(func(v float64) { high = math.Max(high, v) })
+	s.low.each(func(v float64) { low = math.Min(low, v) })
+
+	if high > low {
+		s.value.K = 100 * (c.Close.Float() - low) / (high - low)
+	} else {
+		s.value.K = 50
+	}
+	s.value.D = s.d.Add(s.value.K)
+	return s.value
+}
+
+// Value returns the current value.
+func (s *StochasticStream) Value() StochasticValue { return s.value }
+
+// Ready reports whether enough candles were added for both K and D.
+func (s *StochasticStream) Ready() bool { return s.d.Ready() }
@@ -0,0 +1,88 @@
+package indicators_test
+
+import (
+	"math"
+	"testing"
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/indicators"
+)
+
+func TestRSI(t *testing.T) {
+	// Wilder's 14-period RSI as worked through in the StockCharts
+	// ChartSchool example, rounded to two decimals.
+	candles := closes(
+		44.3389, 44.0902, 44.1497, 43.6124, 44.3278, 44.8264, 45.0955,
+		45.4245, 45.8433, 46.0826, 45.8931, 46.0328, 45.6140, 46.2820,
+		46.2820, 46.0028, 46.0328, 46.4116, 46.2222, 45.6439, 46.2122,
+		46.2521, 45.7137, 46.4515, 45.7835, 45.3548, 44.0288, 44.1783,
+		44.2181, 44.5672, 43.4205, 42.6628, 43.1314,
+	)
+	want := []float64{
+		70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38,
+		54.71, 50.42, 39.99, 41.46, 41.87, 45.46, 37.30, 33.08, 37.77,
+	}
+	got := indicators.RSI(candles, 14)
+	for i := 0; i < 14; i++ {
+		if !math.IsNaN(got[i]) {
+			t.Errorf("RSI[%d] = %v before 14 changes, want NaN", i, got[i])
+		}
+	}
+	for i, w := range want {
+		if g := math.Round(got[14+i]*100) / 100; g != w {
+			t.Errorf("RSI[%d] = %.4f, want %.2f", 14+i, got[14+i], w)
+		}
+	}
+}
+
+func TestRSIWithoutLosses(t *testing.T) {
+	checkSeries(t, "RSI of rising closes", indicators.RSI(closes(1, 2, 3, 4), 2), []float64{nan, nan, 100, 100})
+	checkSeries(t, "RSI of flat closes", indicators.RSI(closes(5, 5, 5), 2), []float64{nan, nan, 50})
+}
+
+func TestMACD(t *testing.T) {
+	// EMA(2) of the closes is 1.5, 2.5, 3.5, 4.5, 49/6 from the second
+	// close and EMA(3) is 2, 3, 4, 7 from the third.
+	got := indicators.MACD(closes(1, 2, 3, 4, 5, 10), 2, 3, 2)
+	want := []indicators.MACDValue{
+		{nan, nan, nan},
+		{nan, nan, nan},
+		{0.5, nan, nan},
+		{0.5, 0.5, 0},
+		{0.5, 0.5, 0},
+		{7.0 / 6, 17.0 / 18, 2.0 / 9},
+	}
+	for i, w := range want {
+		if g := got[i]; !near(g.MACD, w.MACD) || !near(g.Signal, w.Signal) || !near(g.Histogram, w.Histogram) {
+			t.Errorf("MACD[%d] = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestStochastic(t *testing.T) {
+	candles := []*wallex.Candle{
+		candle(0, 10, 8, 9, 1),
+		candle(1, 12, 9, 11, 1),
+		candle(2, 11, 7, 8, 1),   // Range 7-12.
+		candle(3, 13, 10, 12, 1), // Range 7-13.
+		candle(4, 12, 12, 12, 1), // Range 7-13.
+	}
+	got := indicators.Stochastic(candles, 3, 2)
+	want := []indicators.StochasticValue{
+		{nan, nan},
+		{nan, nan},
+		{20, nan},
+		{500.0 / 6, (20 + 500.0/6) / 2},
+		{500.0 / 6, 500.0 / 6},
+	}
+	for i, w := range want {
+		if g := got[i]; !near(g.K, w.K) || !near(g.D, w.D) {
+			t.Errorf("Stochastic[%d] = %+v, want %+v", i, g, w)
+		}
+	}
+
+	flat := indicators.Stochastic(closes(5, 5, 5), 3, 1)
+	if k := flat[2].K; k != 50 {
+		t.Errorf("Stochastic K of an empty range = %v, want 50", k)
+	}
+}
@@ -0,0 +1,138 @@
+package indicators
+
+import (
+	"math"
+
+	wallex "github.com/wallexchange/wallex-go"
+)
+
+// -----------------------------------------------------------------------------
+// Bollinger Bands
+// -----------------------------------------------------------------------------
+
+// Bands is a value of Bollinger Bands.
+type Bands struct {
+	Upper  float64
+	Middle float64 // SMA of closes.
+	Lower  float64
+}
+
+// Width returns the distance between the upper and lower bands relative to
+// the middle band.
+func (b Bands) Width() float64 {
+	return (b.Upper - b.Lower) / b.Middle
+}
+
+// Bollinger returns the Bollinger Bands of the closes of candles, k
+// standard deviations away from their SMA. The usual parameters are 20
+// and 2.
+func Bollinger(candles []*wallex.Candle, period int, k float64) []Bands {
+	s := NewBollingerStream(period, k)
+	out := make([]Bands, len(candles))
+	for i, c := range candles {
+		out[i] = s.Update(c)
+	}
+	return out
+}
+
+// BollingerStream is Bollinger Bands updated incrementally.
+type BollingerStream struct {
+	w     window
+	k     float64
+	value Bands
+}
+
+// NewBollingerStream returns Bollinger Bands of period closes, k standard
+// deviations away from their SMA.
+func NewBollingerStream(period int, k float64) *BollingerStream {
+	checkPeriod("Bollinger", period)
+	return &BollingerStream{w: newWindow(period), k: k, value: Bands{nan, nan, nan}}
+}
+
+// Update adds the close of c and returns the new bands.
+func (s *BollingerStream) Update(c *wallex.Candle) Bands {
+	return s.Add(c.Close.Float())
+}
+
+// Add adds the close v and returns the new bands.
+func (s *BollingerStream) Add(v float64) Bands {
+	s.w.push(v)
+	if !s.w.full {
+		return s.value
+	}
+	// Two passes over the window are slower than running sums but do not
+	// lose precision when the deviation is small relative to the price.
+	n := float64(len(s.w.values))
+	mean := s.w.sum() / n
+	var sq float64
+	s.w.each(func(v float64) { sq += (v - mean) * (v - mean) })
+	dev := s.k * math.Sqrt(sq/n)
+	s.value = Bands{Upper: mean + dev, Middle: mean, Lower: mean - dev}
+	return s.value
+}
+
+// Value returns the current bands.
+func (s *BollingerStream) Value() Bands { return s.value }
+
+// Ready reports whether enough closes were added for the bands.
+func (s *BollingerStream) Ready() bool { return s.w.full }
+
+// -----------------------------------------------------------------------------
+// ATR
+// -----------------------------------------------------------------------------
+
+// ATR returns the average true range of candles with Wilder's smoothing.
+// The usual period is 14.
+func ATR(candles []*wallex.Candle, period int) []float64 {
+	s := NewATRStream(period)
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		out[i] = s.Update(c)
+	}
+	return out
+}
+
+// ATRStream is an average true range updated incrementally. The true range
+// of the first candle, which has no previous close, is its high minus its
+// low.
+type ATRStream struct {
+	period    int
+	prevClose float64
+	n         int
+	sum       float64 // Sum of the first true ranges, before value is defined.
+	value     float64
+}
+
+// NewATRStream returns an average true range of period candles.
+func NewATRStream(period int) *ATRStream {
+	checkPeriod("ATR", period)
+	return &ATRStream{period: period, value: nan}
+}
+
+// Update adds c and returns the new average.
+func (s *ATRStream) Update(c *wallex.Candle) float64 {
+	high, low, cl := c.High.Float(), c.Low.Float(), c.Close.Float()
+	tr := high - low
+	if s.n > 0 {
+		tr = math.Max(tr, math.Max(math.Abs(high-s.prevClose), math.Abs(low-s.prevClose)))
+	}
+	s.prevClose = cl
+	s.n++
+
+	p := float64(s.period)
+	switch {
+	case s.n < s.period:
+		s.sum += tr
+	case s.n == s.period:
+		s.value = (s.sum + tr) / p
+	default:
+		s.value = (s.value*(p-1) + tr) / p
+	}
+	return s.value
+}
+
+// Value returns the current average.
+func (s *ATRStream) Value() float64 { return s.value }
+
+// Ready reports whether enough candles were added for an average.
+func (s *ATRStream) Ready() bool { return s.n >= s.period }
@@ -0,0 +1,46 @@
+package indicators_test
+
+import (
+	"testing"
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/indicators"
+)
+
+func TestBollinger(t *testing.T) {
+	// The closes have a mean of 5 and a population standard deviation of 2.
+	got := indicators.Bollinger(closes(2, 4, 4, 4, 5, 5, 7, 9), 8, 2)
+	for i := 0; i < 7; i++ {
+		if b := got[i]; !near(b.Middle, nan) {
+			t.Errorf("Bollinger[%d] = %+v before 8 closes, want NaN", i, b)
+		}
+	}
+	want := indicators.Bands{Upper: 9, Middle: 5, Lower: 1}
+	if b := got[7]; !near(b.Upper, want.Upper) || !near(b.Middle, want.Middle) || !near(b.Lower, want.Lower) {
+		t.Errorf("Bollinger[7] = %+v, want %+v", b, want)
+	}
+	if w := got[7].Width(); !near(w, 1.6) {
+		t.Errorf("Width = %v, want 1.6", w)
+	}
+}
+
+func TestBollingerSmallDeviation(t *testing.T) {
+	// A deviation tiny relative to the price survives.
+	got := indicators.Bollinger(closes(1e9, 1e9+2, 1e9, 1e9+2), 4, 1)
+	if b := got[3]; !near(b.Upper-b.Middle, 1) {
+		t.Errorf("Bollinger = %+v, want bands 1 away from the middle", b)
+	}
+}
+
+func TestATR(t *testing.T) {
+	candles := []*wallex.Candle{
+		candle(0, 10, 8, 9, 1),      // TR 2, the range of the first candle.
+		candle(1, 11, 9, 10, 1),     // TR 2.
+		candle(2, 10.5, 9.5, 10, 1), // TR 1.
+		candle(3, 14, 12, 13, 1),    // TR 4, a gap up from 10.
+		candle(4, 13, 8, 9, 1),      // TR 5.
+	}
+	// The first average is the mean of 3 true ranges, then each is
+	// smoothed with Wilder's (ATR*2 + TR)/3.
+	checkSeries(t, "ATR", indicators.ATR(candles, 3), []float64{nan, nan, 5.0 / 3, 22.0 / 9, 89.0 / 27})
+}
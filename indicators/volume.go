@@ -0,0 +1,120 @@
+package indicators
+
+import (
+	"time"
+
+	wallex "github.com/wallexchange/wallex-go"
+)
+
+// -----------------------------------------------------------------------------
+// OBV
+// -----------------------------------------------------------------------------
+
+// OBV returns the on-balance volume of candles, starting from 0 at the
+// first candle.
+func OBV(candles []*wallex.Candle) []float64 {
+	s := NewOBVStream()
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		out[i] = s.Update(c)
+	}
+	return out
+}
+
+// OBVStream is an on-balance volume updated incrementally.
+type OBVStream struct {
+	prevClose float64
+	n         int
+	value     float64
+}
+
+// NewOBVStream returns an on-balance volume.
+func NewOBVStream() *OBVStream {
+	return &OBVStream{}
+}
+
+// Update adds c and returns the new volume. The volume of c is added if it
+// closed higher than the previous candle and subtracted if it closed lower.
+func (s *OBVStream) Update(c *wallex.Candle) float64 {
+	cl := c.Close.Float()
+	if s.n > 0 {
+		switch {
+		case cl > s.prevClose:
+			s.value += c.Volume.Float()
+		case cl < s.prevClose:
+			s.value -= c.Volume.Float()
+		}
+	}
+	s.prevClose = cl
+	s.n++
+	return s.value
+}
+
+// Value returns the current volume.
+func (s *OBVStream) Value() float64 { return s.value }
+
+// Ready reports whether a candle was added.
+func (s *OBVStream) Ready() bool { return s.n > 0 }
+
+// -----------------------------------------------------------------------------
+// VWAP
+// -----------------------------------------------------------------------------
+
+// VWAP returns the volume-weighted average of the typical prices,
+// (high+low+close)/3, of candles. If loc is not nil, the average starts
+// over with the first candle of each day in loc, e.g. jalali.Tehran().
+// Otherwise it is anchored at the first candle.
+func VWAP(candles []*wallex.Candle, loc *time.Location) []float64 {
+	s := NewVWAPStream(loc)
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		out[i] = s.Update(c)
+	}
+	return out
+}
+
+// VWAPStream is a volume-weighted average price updated incrementally.
+type VWAPStream struct {
+	loc    *time.Location
+	day    time.Time
+	volume float64
+	total  float64
+	value  float64
+}
+
+// NewVWAPStream returns a volume-weighted average price that starts over
+// every day in loc, or never if loc is nil.
+func NewVWAPStream(loc *time.Location) *VWAPStream {
+	return &VWAPStream{loc: loc, value: nan}
+}
+
+// Update adds c and returns the new average. It is NaN until some volume
+// was traded in the current session.
+func (s *VWAPStream) Update(c *wallex.Candle) float64 {
+	if s.loc != nil {
+		y, m, d := c.Timestamp.In(s.loc).Date()
+		if day := time.Date(y, m, d, 0, 0, 0, 0, s.loc); !day.Equal(s.day) {
+			s.Reset()
+			s.day = day
+		}
+	}
+	typical := (c.High.Float() + c.Low.Float() + c.Close.Float()) / 3
+	volume := c.Volume.Float()
+	s.volume += volume
+	s.total += typical * volume
+	if s.volume > 0 {
+		s.value = s.total / s.volume
+	}
+	return s.value
+}
+
+// Reset starts a new session, e.g. at an anchor of the caller's choosing.
+func (s *VWAPStream) Reset() {
+	s.volume, s.total, s.value = 0, 0, nan
+}
+
+// Value returns the current average.
+func (s *VWAPStream) Value() float64 { return s.value }
+
+// Ready reports whether some volume was traded in the current session.
+func (s *VWAPStream) Ready() bool { return s.volume > 0 }
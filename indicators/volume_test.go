@@ -0,0 +1,41 @@
+package indicators_test
+
+import (
+	"testing"
+	"time"
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/indicators"
+	"github.com/wallexchange/wallex-go/jalali"
+)
+
+func TestOBV(t *testing.T) {
+	candles := []*wallex.Candle{
+		candle(0, 10, 10, 10, 1),
+		candle(1, 11, 11, 11, 2),
+		candle(2, 11, 11, 11, 3),
+		candle(3, 9, 9, 9, 4),
+		candle(4, 12, 12, 12, 5),
+	}
+	checkSeries(t, "OBV", indicators.OBV(candles), []float64{0, 2, 2, -2, 3})
+}
+
+func TestVWAP(t *testing.T) {
+	at := func(c *wallex.Candle, ts string) *wallex.Candle {
+		var err error
+		if c.Timestamp, err = time.Parse(time.RFC3339, ts); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	candles := []*wallex.Candle{
+		at(candle(0, 3, 1, 2, 1), "2024-03-20T20:00:00Z"),   // 23:30 in Tehran.
+		at(candle(0, 6, 4, 5, 3), "2024-03-20T20:15:00Z"),   // 23:45.
+		at(candle(0, 9, 7, 8, 0), "2024-03-20T20:30:00Z"),   // 00:00 the next day.
+		at(candle(0, 11, 9, 10, 2), "2024-03-20T20:45:00Z"), // 00:15.
+	}
+
+	// Typical prices are 2, 5, 8 and 10.
+	checkSeries(t, "anchored VWAP", indicators.VWAP(candles, nil), []float64{2, 17.0 / 4, 17.0 / 4, 37.0 / 6})
+	checkSeries(t, "daily VWAP", indicators.VWAP(candles, jalali.Tehran()), []float64{2, 17.0 / 4, nan, 10})
+}
@@ -0,0 +1,111 @@
+// Package redact removes secrets and personal information from
+// HTTP headers and JSON payloads before they are logged or stored.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Placeholder replaces redacted values.
+const Placeholder = "[REDACTED]"
+
+// SensitiveKeys is the set of JSON object keys whose values are redacted.
+// Keys are matched case-insensitively.
+var SensitiveKeys = map[string]bool{
+	"first_name":          true,
+	"last_name":           true,
+	"national_code":       true,
+	"national_card_image": true,
+	"face_image":          true,
+	"birthday":            true,
+	"address":             true,
+	"phone_number":        true,
+	"mobile_number":       true,
+	"email":               true,
+	"avatar":              true,
+	"invite_code":         true,
+	"iban":                true,
+	"card_number":         true,
+	"owners":              true,
+	"tracking_id":         true,
+}
+
+// Header returns a copy of h with values of the given headers redacted.
+func Header(h http.Header, names ...string) http.Header {
+	h = h.Clone()
+	for _, name := range names {
+		if _, ok := h[http.CanonicalHeaderKey(name)]; ok {
+			h.Set(name, Placeholder)
+		}
+	}
+	return h
+}
+
+// JSON returns a copy of data with values of SensitiveKeys redacted.
+// Redacted values keep their JSON types; strings are replaced with
+// Placeholder, timestamps with the zero time and numbers with zero.
+// If data is not valid JSON, it is returned unchanged.
+func JSON(data []byte) []byte {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return data
+	}
+	out, err := json.Marshal(value(v))
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if SensitiveKeys[strings.ToLower(k)] {
+				v[k] = scrub(e)
+				continue
+			}
+			v[k] = value(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = value(e)
+		}
+	}
+	return v
+}
+
+// scrub replaces all leaves of v, keeping their JSON types,
+// so that redacted payloads still decode into the same Go types.
+func scrub(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = scrub(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = scrub(e)
+		}
+		return v
+	case string:
+		if _, err := time.Parse(time.RFC3339, v); err == nil {
+			return zeroTime
+		}
+		return Placeholder
+	case json.Number:
+		return json.Number("0")
+	default:
+		return v
+	}
+}
+
+// zeroTime replaces redacted timestamps.
+const zeroTime = "0001-01-01T00:00:00Z"
@@ -0,0 +1,527 @@
+// Package websocket implements the subset of the WebSocket protocol
+// (RFC 6455) needed by the streaming client and its test server:
+// an opening handshake on both sides, optionally through an HTTP proxy,
+// unfragmented writes and reads of fragmented messages. Extensions and
+// subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// List of message types.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// MaxMessageSize is the largest message ReadMessage accepts.
+const MaxMessageSize = 16 << 20
+
+// acceptGUID is the GUID appended to the handshake key, see RFC 6455 1.3.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// CloseError is returned by ReadMessage when the peer closes the connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: closed with code %d: %s", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection. ReadMessage must not be called
+// concurrently; writes are safe for concurrent use.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// HandshakeError is returned by Dial when the server does not switch
+// protocols, e.g. because it rejects the request.
+type HandshakeError struct {
+	StatusCode int
+	Status     string
+	Body       []byte // Prefix of the response body.
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: handshake failed with status " + e.Status
+}
+
+// maxHandshakeBody limits how much of a rejected handshake is read.
+const maxHandshakeBody = 4 << 10
+
+// Dialer contains options for connecting to a WebSocket server.
+// The zero value dials directly with default TLS settings.
+type Dialer struct {
+
+	// DialContext dials TCP connections. If nil, net.Dialer is used.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// TLSClientConfig is used for wss URLs. If nil, the default
+	// configuration is used.
+	TLSClientConfig *tls.Config
+
+	// Proxy returns the proxy of a request, as http.Transport.Proxy does.
+	// The request URL has an http or https scheme for ws and wss URLs.
+	// Connections are tunneled through HTTP proxies with CONNECT.
+	// If nil or if it returns a nil URL, no proxy is used.
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+// Dial opens a WebSocket connection to rawURL with the zero Dialer.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, error) {
+	var d Dialer
+	return d.Dial(ctx, rawURL, header)
+}
+
+// Dial opens a WebSocket connection to rawURL, whose scheme is one of
+// ws, wss, http or https. header is sent with the handshake request.
+func (d *Dialer) Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	secure := false
+	switch u.Scheme {
+	case "ws", "http":
+	case "wss", "https":
+		secure = true
+	default:
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	addr := hostPort(u.Host, u.Port(), secure)
+
+	var proxy *url.URL
+	if d.Proxy != nil {
+		pu := *u
+		pu.Scheme = "http"
+		if secure {
+			pu.Scheme = "https"
+		}
+		proxy, err = d.Proxy(&http.Request{Method: http.MethodGet, URL: &pu, Header: http.Header{}})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var conn net.Conn
+	if proxy != nil {
+		conn, err = d.dialProxy(ctx, proxy, addr)
+	} else {
+		conn, err = d.dial(ctx, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if secure {
+		conn, err = d.handshakeTLS(ctx, conn, u.Hostname())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := handshake(ctx, conn, u, header)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func hostPort(host, port string, secure bool) string {
+	if port != "" {
+		return host
+	}
+	h := strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if secure {
+		return net.JoinHostPort(h, "443")
+	}
+	return net.JoinHostPort(h, "80")
+}
+
+func (d *Dialer) dial(ctx context.Context, addr string) (net.Conn, error) {
+	if d.DialContext != nil {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	var nd net.Dialer
+	return nd.DialContext(ctx, "tcp", addr)
+}
+
+// handshakeTLS wraps conn in a TLS client connection to server.
+// It closes conn on failure.
+func (d *Dialer) handshakeTLS(ctx context.Context, conn net.Conn, server string) (net.Conn, error) {
+	cfg := &tls.Config{}
+	if d.TLSClientConfig != nil {
+		cfg = d.TLSClientConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = server
+	}
+	// The handshake is an HTTP/1.1 request, whatever else the
+	// configuration offers.
+	cfg.NextProtos = []string{"http/1.1"}
+	tc := tls.Client(conn, cfg)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// dialProxy opens a tunnel to addr through an HTTP or HTTPS proxy.
+func (d *Dialer) dialProxy(ctx context.Context, proxy *url.URL, addr string) (net.Conn, error) {
+	secure := false
+	switch proxy.Scheme {
+	case "http", "":
+	case "https":
+		secure = true
+	default:
+		return nil, fmt.Errorf("websocket: unsupported proxy scheme %q", proxy.Scheme)
+	}
+	conn, err := d.dial(ctx, hostPort(proxy.Host, proxy.Port(), secure))
+	if err != nil {
+		return nil, err
+	}
+	if secure {
+		conn, err = d.handshakeTLS(ctx, conn, proxy.Hostname())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The proxy sends nothing after its response until the tunnel is
+	// used, so the buffered reader holds no tunneled data.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: proxy refused tunnel with status %s", resp.Status)
+	}
+	return conn, nil
+}
+
+func handshake(ctx context.Context, conn net.Conn, u *url.URL, header http.Header) (*Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "GET %s HTTP/1.1\r\n", u.RequestURI())
+	fmt.Fprintf(&b, "Host: %s\r\n", u.Host)
+	b.WriteString("Upgrade: websocket\r\n")
+	b.WriteString("Connection: Upgrade\r\n")
+	fmt.Fprintf(&b, "Sec-WebSocket-Key: %s\r\n", key)
+	b.WriteString("Sec-WebSocket-Version: 13\r\n")
+	for k, vs := range header {
+		for _, v := range vs {
+			fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+		}
+	}
+	b.WriteString("\r\n")
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxHandshakeBody))
+		resp.Body.Close()
+		return nil, &HandshakeError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       body,
+		}
+	}
+	resp.Body.Close()
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, errors.New("websocket: invalid handshake response")
+	}
+	return &Conn{conn: conn, br: br, client: true}, nil
+}
+
+// Upgrade upgrades an HTTP server request to a WebSocket connection.
+// On failure, it replies to the request with an HTTP error.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "websocket: bad handshake", http.StatusBadRequest)
+		return nil, errors.New("websocket: bad handshake")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket: hijacking not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: hijacking not supported")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, br: brw.Reader}, nil
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// SetReadDeadline sets the deadline of future ReadMessage calls.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// ReadMessage reads the next text or binary message. Pings are answered
+// and pongs are discarded. If the peer closes the connection, the close is
+// acknowledged and a *CloseError is returned.
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case PingMessage:
+			if err := c.WriteMessage(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			ce := &CloseError{Code: 1005}
+			if len(payload) >= 2 {
+				ce.Code = int(binary.BigEndian.Uint16(payload))
+				ce.Reason = string(payload[2:])
+			}
+			// Echo the status code, as RFC 6455 5.5.1 suggests.
+			ack := payload[:0:0]
+			if len(payload) >= 2 {
+				ack = payload[:2]
+			}
+			c.WriteMessage(CloseMessage, ack)
+			c.conn.Close()
+			return 0, nil, ce
+		case TextMessage, BinaryMessage:
+		default:
+			return 0, nil, fmt.Errorf("websocket: unexpected opcode %d", op)
+		}
+
+		messageType, data = op, payload
+		for !fin {
+			last, op, cont, err := c.readFrame()
+			if err != nil {
+				return 0, nil, err
+			}
+			switch op {
+			case 0:
+				fin = last
+			case PingMessage:
+				if err := c.WriteMessage(PongMessage, cont); err != nil {
+					return 0, nil, err
+				}
+				continue
+			case PongMessage:
+				continue
+			default:
+				return 0, nil, fmt.Errorf("websocket: unexpected opcode %d in fragmented message", op)
+			}
+			if len(data)+len(cont) > MaxMessageSize {
+				return 0, nil, errors.New("websocket: message too large")
+			}
+			data = append(data, cont...)
+		}
+		return messageType, data, nil
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, op int, payload []byte, err error) {
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = h[0]&0x80 != 0
+	op = int(h[0] & 0x0f)
+	masked := h[1]&0x80 != 0
+	if masked == c.client {
+		// Clients must mask their frames and servers must not.
+		return false, 0, nil, errors.New("websocket: invalid frame masking")
+	}
+
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > MaxMessageSize {
+		return false, 0, nil, errors.New("websocket: message too large")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		maskBytes(mask, payload)
+	}
+	return fin, op, payload, nil
+}
+
+// WriteMessage writes a message of the given type as a single frame.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	frame := make([]byte, 0, len(data)+14)
+	frame = append(frame, 0x80|byte(messageType))
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(data); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(frame, maskBit|127)
+		frame = append(frame, ext[:]...)
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		frame = append(frame, data...)
+		maskBytes(mask, frame[len(frame)-len(data):])
+	} else {
+		frame = append(frame, data...)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	if messageType == CloseMessage {
+		c.closed = true
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
+
+// Close sends a normal closure message, if none was sent yet,
+// and closes the underlying connection.
+func (c *Conn) Close() error {
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.WriteMessage(CloseMessage, []byte{0x03, 0xe8})
+	return c.conn.Close()
+}
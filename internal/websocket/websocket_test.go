@@ -0,0 +1,344 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipe returns a client and a server connection joined by net.Pipe.
+func pipe(t *testing.T) (client, server *Conn) {
+	c1, c2 := net.Pipe()
+	t.Cleanup(func() {
+		c1.Close()
+		c2.Close()
+	})
+	client = &Conn{conn: c1, br: bufio.NewReader(c1), client: true}
+	server = &Conn{conn: c2, br: bufio.NewReader(c2)}
+	return client, server
+}
+
+// rawFrame encodes a frame, masked with mask if not nil.
+func rawFrame(fin bool, op int, payload []byte, mask []byte) []byte {
+	var b bytes.Buffer
+	h := byte(op)
+	if fin {
+		h |= 0x80
+	}
+	b.WriteByte(h)
+	var maskBit byte
+	if mask != nil {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		b.WriteByte(maskBit | byte(n))
+	case n <= 0xffff:
+		b.WriteByte(maskBit | 126)
+		binary.Write(&b, binary.BigEndian, uint16(n))
+	default:
+		b.WriteByte(maskBit | 127)
+		binary.Write(&b, binary.BigEndian, uint64(n))
+	}
+	p := append([]byte(nil), payload...)
+	if mask != nil {
+		b.Write(mask)
+		for i := range p {
+			p[i] ^= mask[i%4]
+		}
+	}
+	b.Write(p)
+	return b.Bytes()
+}
+
+func TestClientMasksFrames(t *testing.T) {
+	client, server := pipe(t)
+	go client.WriteMessage(TextMessage, []byte("hello"))
+
+	var h [6]byte
+	if _, err := io.ReadFull(server.br, h[:]); err != nil {
+		t.Fatal(err)
+	}
+	if h[0] != 0x80|TextMessage {
+		t.Errorf("first byte = %#x, want FIN and text opcode", h[0])
+	}
+	if h[1] != 0x80|5 {
+		t.Fatalf("second byte = %#x, want mask bit and length 5", h[1])
+	}
+	payload := make([]byte, 5)
+	if _, err := io.ReadFull(server.br, payload); err != nil {
+		t.Fatal(err)
+	}
+	if string(payload) == "hello" && h[2]|h[3]|h[4]|h[5] != 0 {
+		t.Error("payload is not masked")
+	}
+	for i := range payload {
+		payload[i] ^= h[2+i%4]
+	}
+	if string(payload) != "hello" {
+		t.Errorf("unmasked payload = %q, want hello", payload)
+	}
+}
+
+func TestServerDoesNotMaskFrames(t *testing.T) {
+	client, server := pipe(t)
+	go server.WriteMessage(TextMessage, []byte("hello"))
+
+	want := rawFrame(true, TextMessage, []byte("hello"), nil)
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(client.br, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("frame = %x, want %x", got, want)
+	}
+}
+
+func TestRejectsWrongMasking(t *testing.T) {
+	client, server := pipe(t)
+
+	// A server must not accept unmasked frames from a client...
+	go client.conn.Write(rawFrame(true, TextMessage, []byte("x"), nil))
+	if _, _, err := server.ReadMessage(); err == nil {
+		t.Error("server accepted an unmasked frame")
+	}
+
+	// ...and a client must not accept masked frames from a server.
+	go server.conn.Write(rawFrame(true, TextMessage, []byte("x"), []byte{1, 2, 3, 4}))
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Error("client accepted a masked frame")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 125, 126, 0xffff, 0x10000} {
+		client, server := pipe(t)
+		data := bytes.Repeat([]byte("a"), n)
+
+		errc := make(chan error, 1)
+		go func() { errc <- client.WriteMessage(BinaryMessage, data) }()
+		typ, got, err := server.ReadMessage()
+		if err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if typ != BinaryMessage || !bytes.Equal(got, data) {
+			t.Errorf("%d bytes: got type %d and %d bytes", n, typ, len(got))
+		}
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+
+		go func() { errc <- server.WriteMessage(TextMessage, data) }()
+		typ, got, err = client.ReadMessage()
+		if err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if typ != TextMessage || !bytes.Equal(got, data) {
+			t.Errorf("%d bytes: got type %d and %d bytes", n, typ, len(got))
+		}
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFragmentedMessage(t *testing.T) {
+	client, server := pipe(t)
+
+	// The client answers the ping between fragments, so frames it sends
+	// are read concurrently.
+	frames := make(chan []byte, 1)
+	go func() {
+		for {
+			fin, op, payload, err := server.readFrame()
+			if err != nil {
+				close(frames)
+				return
+			}
+			if fin && op == PongMessage {
+				frames <- payload
+			}
+		}
+	}()
+	go func() {
+		server.conn.Write(rawFrame(false, TextMessage, []byte("Hel"), nil))
+		server.conn.Write(rawFrame(true, PingMessage, []byte("p"), nil))
+		server.conn.Write(rawFrame(false, 0, []byte("lo "), nil))
+		server.conn.Write(rawFrame(true, 0, []byte("world"), nil))
+	}()
+
+	typ, got, err := client.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != TextMessage || string(got) != "Hello world" {
+		t.Errorf("got type %d %q, want text %q", typ, got, "Hello world")
+	}
+	if pong := <-frames; string(pong) != "p" {
+		t.Errorf("pong = %q, want p", pong)
+	}
+}
+
+func TestFragmentedMessageRejectsNewMessage(t *testing.T) {
+	client, server := pipe(t)
+	go func() {
+		server.conn.Write(rawFrame(false, TextMessage, []byte("a"), nil))
+		server.conn.Write(rawFrame(true, TextMessage, []byte("b"), nil))
+	}()
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Error("data frame inside a fragmented message was accepted")
+	}
+}
+
+func TestClose(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		want    CloseError
+	}{
+		{[]byte("\x03\xe9going away"), CloseError{Code: 1001, Reason: "going away"}},
+		{nil, CloseError{Code: 1005}},
+	}
+	for _, tt := range tests {
+		client, server := pipe(t)
+
+		ack := make(chan error, 1)
+		go func() {
+			if err := server.WriteMessage(CloseMessage, tt.payload); err != nil {
+				ack <- err
+				return
+			}
+			_, _, err := server.ReadMessage()
+			ack <- err
+		}()
+
+		_, _, err := client.ReadMessage()
+		var ce *CloseError
+		if !errors.As(err, &ce) || *ce != tt.want {
+			t.Errorf("client got %v, want %v", err, &tt.want)
+		}
+
+		// The close is acknowledged with the same status code.
+		err = <-ack
+		ce = nil
+		if !errors.As(err, &ce) || ce.Code != tt.want.Code {
+			t.Errorf("server got %v, want code %d", err, tt.want.Code)
+		}
+
+		if err := server.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, net.ErrClosed) {
+			t.Errorf("write after close = %v, want net.ErrClosed", err)
+		}
+	}
+}
+
+func newEchoServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("reject") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"code":5}`)
+			return
+		}
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if conn.WriteMessage(typ, data) != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func echo(t *testing.T, conn *Conn) {
+	t.Helper()
+	if err := conn.WriteMessage(TextMessage, []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "ping" {
+		t.Fatalf("echo = %q, %v", data, err)
+	}
+}
+
+func TestDial(t *testing.T) {
+	srv := newEchoServer(t)
+	conn, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	echo(t, conn)
+}
+
+func TestDialHandshakeError(t *testing.T) {
+	srv := newEchoServer(t)
+	_, err := Dial(context.Background(), srv.URL+"/?reject=1", nil)
+	var he *HandshakeError
+	if !errors.As(err, &he) {
+		t.Fatalf("got %v, want a *HandshakeError", err)
+	}
+	if he.StatusCode != http.StatusBadRequest || string(he.Body) != `{"code":5}` {
+		t.Errorf("got status %d and body %q", he.StatusCode, he.Body)
+	}
+}
+
+func TestDialThroughProxy(t *testing.T) {
+	target := newEchoServer(t)
+
+	tunnels := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		tunnels <- r.Host
+		up, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			up.Close()
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() {
+			io.Copy(up, brw)
+			up.Close()
+		}()
+		io.Copy(conn, up)
+		conn.Close()
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	d := &Dialer{Proxy: http.ProxyURL(proxyURL)}
+	conn, err := d.Dial(context.Background(), "ws"+strings.TrimPrefix(target.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	echo(t, conn)
+
+	if host := <-tunnels; host != strings.TrimPrefix(target.URL, "http://") {
+		t.Errorf("tunnel to %q, want the target", host)
+	}
+}
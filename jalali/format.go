@@ -0,0 +1,170 @@
+package jalali
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var farsiWeekdays = [...]string{
+	"یکشنبه", "دوشنبه", "سه‌شنبه", "چهارشنبه", "پنجشنبه", "جمعه", "شنبه",
+}
+
+// Format formats t in its location as a Jalali date using strftime-like
+// directives:
+//
+//	%Y  year, e.g. 1402          %H  hour, 00-23
+//	%y  two-digit year, e.g. 02  %M  minute, 00-59
+//	%m  month, 01-12             %S  second, 00-59
+//	%d  day, 01-31               %z  zone offset, e.g. +0330
+//	%j  day of year, 001-366     %Z  zone name, e.g. +0330
+//	%B  Persian month name       %A  Persian weekday name
+//	%b  English month name       %a  English weekday name
+//	%%  a literal %
+//
+// Unknown directives are copied as is.
+func Format(t time.Time, layout string) string {
+	d := FromTime(t)
+	var b strings.Builder
+	for i := 0; i < len(layout); i++ {
+		c := layout[i]
+		if c != '%' || i+1 == len(layout) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch layout[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", d.Year)
+		case 'y':
+			fmt.Fprintf(&b, "%02d", d.Year%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(d.Month))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", d.Day)
+		case 'j':
+			fmt.Fprintf(&b, "%03d", d.YearDay())
+		case 'B':
+			b.WriteString(d.Month.FarsiName())
+		case 'b':
+			b.WriteString(d.Month.String())
+		case 'A':
+			b.WriteString(farsiWeekdays[t.Weekday()])
+		case 'a':
+			b.WriteString(t.Weekday().String())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(layout[i])
+		}
+	}
+	return b.String()
+}
+
+// Parse parses a Jalali date formatted with layout and returns it in loc.
+// It supports the numeric directives %Y, %y, %m, %d, %H, %M and %S, where
+// %y is a year in the 1300s or 1400s. Persian digits are accepted.
+func Parse(layout, value string, loc *time.Location) (time.Time, error) {
+	value = latinDigits(value)
+	year, month, day, hour, minute, sec := 0, 1, 1, 0, 0, 0
+	v := value
+	for i := 0; i < len(layout); i++ {
+		c := layout[i]
+		if c != '%' || i+1 == len(layout) {
+			if len(v) == 0 || v[0] != c {
+				return time.Time{}, parseError(layout, value)
+			}
+			v = v[1:]
+			continue
+		}
+		i++
+		var field *int
+		width := 2
+		switch layout[i] {
+		case 'Y':
+			field, width = &year, 4
+		case 'y':
+			field = &year
+		case 'm':
+			field = &month
+		case 'd':
+			field = &day
+		case 'H':
+			field = &hour
+		case 'M':
+			field = &minute
+		case 'S':
+			field = &sec
+		case '%':
+			if len(v) == 0 || v[0] != '%' {
+				return time.Time{}, parseError(layout, value)
+			}
+			v = v[1:]
+			continue
+		default:
+			return time.Time{}, fmt.Errorf("jalali: unsupported directive %%%c in layout %q", layout[i], layout)
+		}
+
+		n := 0
+		for n < len(v) && n < width && '0' <= v[n] && v[n] <= '9' {
+			n++
+		}
+		if n == 0 {
+			return time.Time{}, parseError(layout, value)
+		}
+		x, _ := strconv.Atoi(v[:n])
+		if layout[i] == 'y' {
+			if x < 50 {
+				x += 1400
+			} else {
+				x += 1300
+			}
+		}
+		*field = x
+		v = v[n:]
+	}
+	if v != "" {
+		return time.Time{}, parseError(layout, value)
+	}
+
+	d, err := NewDate(year, Month(month), day)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if hour > 23 || minute > 59 || sec > 59 {
+		return time.Time{}, parseError(layout, value)
+	}
+	// The wall clock is set directly rather than added to midnight, which
+	// would be off by the shift on days of a daylight saving transition.
+	gy, gm, gd := jdnToGregorian(d.jdn())
+	return time.Date(gy, time.Month(gm), gd, hour, minute, sec, 0, loc), nil
+}
+
+func parseError(layout, value string) error {
+	return fmt.Errorf("jalali: cannot parse %q as %q", value, layout)
+}
+
+// latinDigits replaces Persian and Arabic-Indic digits in s with ASCII digits.
+func latinDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case '۰' <= r && r <= '۹':
+			return '0' + r - '۰'
+		case '٠' <= r && r <= '٩':
+			return '0' + r - '٠'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,322 @@
+// Package jalali converts between Gregorian and Jalali (Solar Hijri) dates,
+// formats and parses Jalali dates and computes Jalali month and fiscal-year
+// boundaries, usually in Asia/Tehran time.
+//
+// Conversions are exact for Jalali years -61 to 3177.
+package jalali
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Month is a Jalali month.
+type Month int
+
+// List of Jalali months.
+const (
+	Farvardin Month = 1 + iota
+	Ordibehesht
+	Khordad
+	Tir
+	Mordad
+	Shahrivar
+	Mehr
+	Aban
+	Azar
+	Dey
+	Bahman
+	Esfand
+)
+
+var monthNames = [...]string{
+	"Farvardin", "Ordibehesht", "Khordad", "Tir", "Mordad", "Shahrivar",
+	"Mehr", "Aban", "Azar", "Dey", "Bahman", "Esfand",
+}
+
+var farsiMonthNames = [...]string{
+	"فروردین", "اردیبهشت", "خرداد", "تیر", "مرداد", "شهریور",
+	"مهر", "آبان", "آذر", "دی", "بهمن", "اسفند",
+}
+
+// String returns the English transliteration of m, e.g. "Farvardin".
+func (m Month) String() string {
+	if m < Farvardin || m > Esfand {
+		return fmt.Sprintf("%%!Month(%d)", int(m))
+	}
+	return monthNames[m-1]
+}
+
+// FarsiName returns the Persian name of m, e.g. "فروردین".
+func (m Month) FarsiName() string {
+	if m < Farvardin || m > Esfand {
+		return m.String()
+	}
+	return farsiMonthNames[m-1]
+}
+
+// Date is a Jalali calendar date.
+type Date struct {
+	Year  int
+	Month Month
+	Day   int
+}
+
+// NewDate returns the date of the given Jalali year, month and day.
+// It fails if the date does not exist.
+func NewDate(year int, month Month, day int) (Date, error) {
+	d := Date{Year: year, Month: month, Day: day}
+	if !d.IsValid() {
+		return Date{}, fmt.Errorf("jalali: invalid date %d/%d/%d", year, int(month), day)
+	}
+	return d, nil
+}
+
+// FromTime returns the Jalali date of t in its location.
+func FromTime(t time.Time) Date {
+	gy, gm, gd := t.Date()
+	return fromJDN(gregorianToJDN(gy, int(gm), gd))
+}
+
+// Time returns midnight at the beginning of d in loc.
+func (d Date) Time(loc *time.Location) time.Time {
+	gy, gm, gd := jdnToGregorian(d.jdn())
+	return time.Date(gy, time.Month(gm), gd, 0, 0, 0, 0, loc)
+}
+
+// IsValid reports whether d exists in the Jalali calendar.
+func (d Date) IsValid() bool {
+	if _, ok := calendar(d.Year); !ok {
+		return false
+	}
+	return d.Month >= Farvardin && d.Month <= Esfand && d.Day >= 1 && d.Day <= DaysIn(d.Year, d.Month)
+}
+
+// AddDays returns d shifted by n days.
+func (d Date) AddDays(n int) Date {
+	return fromJDN(d.jdn() + n)
+}
+
+// AddMonths returns d shifted by n months. The day is clamped to the length
+// of the resulting month, so Shahrivar 31 plus one month is Mehr 30.
+func (d Date) AddMonths(n int) Date {
+	m := int(d.Month) - 1 + n
+	y := d.Year + m/12
+	m %= 12
+	if m < 0 {
+		m += 12
+		y--
+	}
+	r := Date{Year: y, Month: Month(m + 1), Day: d.Day}
+	if days := DaysIn(r.Year, r.Month); r.Day > days {
+		r.Day = days
+	}
+	return r
+}
+
+// Weekday returns the day of the week of d.
+func (d Date) Weekday() time.Weekday {
+	// JDN 0 was a Monday.
+	return time.Weekday((d.jdn() + 1) % 7)
+}
+
+// YearDay returns the day of the year of d, in the range [1, 366].
+func (d Date) YearDay() int {
+	if d.Month <= 7 {
+		return int(d.Month-1)*31 + d.Day
+	}
+	return 6*31 + int(d.Month-7)*30 + d.Day
+}
+
+// Before reports whether d is before e.
+func (d Date) Before(e Date) bool {
+	return d.jdn() < e.jdn()
+}
+
+// String formats d as "1402/01/15".
+func (d Date) String() string {
+	return fmt.Sprintf("%04d/%02d/%02d", d.Year, int(d.Month), d.Day)
+}
+
+func (d Date) jdn() int {
+	c, _ := calendar(d.Year)
+	return gregorianToJDN(c.gy, 3, c.march) + int(d.Month-1)*31 - int(d.Month)/7*int(d.Month-7) + d.Day - 1
+}
+
+// IsLeap reports whether year is a leap Jalali year.
+func IsLeap(year int) bool {
+	c, ok := calendar(year)
+	return ok && c.leap == 0
+}
+
+// DaysIn returns the number of days in month m of year.
+func DaysIn(year int, m Month) int {
+	switch {
+	case m <= Shahrivar:
+		return 31
+	case m <= Bahman:
+		return 30
+	case IsLeap(year):
+		return 30
+	default:
+		return 29
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Tehran time
+// -----------------------------------------------------------------------------
+
+var (
+	tehranOnce sync.Once
+	tehran     *time.Location
+)
+
+// Tehran returns the Asia/Tehran location. If the time zone database is not
+// available, it falls back to a fixed +03:30 zone, which has been Iran's
+// offset all year round since 2022.
+func Tehran() *time.Location {
+	tehranOnce.Do(func() {
+		loc, err := time.LoadLocation("Asia/Tehran")
+		if err != nil {
+			loc = time.FixedZone("+0330", 3*60*60+30*60)
+		}
+		tehran = loc
+	})
+	return tehran
+}
+
+// InTehran returns t in Tehran time.
+func InTehran(t time.Time) time.Time {
+	return t.In(Tehran())
+}
+
+// Today returns the current Jalali date in Tehran.
+func Today() Date {
+	return FromTime(InTehran(time.Now()))
+}
+
+// -----------------------------------------------------------------------------
+// Boundaries
+// -----------------------------------------------------------------------------
+
+// MonthRange returns the beginning of the Jalali month containing t and the
+// beginning of the next one, in the location of t.
+func MonthRange(t time.Time) (start, end time.Time) {
+	d := FromTime(t)
+	first := Date{Year: d.Year, Month: d.Month, Day: 1}
+	return first.Time(t.Location()), first.AddMonths(1).Time(t.Location())
+}
+
+// FiscalYearRange returns the beginning of the Iranian fiscal year containing
+// t, i.e. Farvardin 1, and the beginning of the next one, in the location of t.
+func FiscalYearRange(t time.Time) (start, end time.Time) {
+	y := FromTime(t).Year
+	return FiscalYear(y, t.Location())
+}
+
+// MonthOf returns the beginning of month m of year and the beginning of the
+// next month in loc.
+func MonthOf(year int, m Month, loc *time.Location) (start, end time.Time) {
+	first := Date{Year: year, Month: m, Day: 1}
+	return first.Time(loc), first.AddMonths(1).Time(loc)
+}
+
+// FiscalYear returns the beginning of the fiscal year and the beginning of
+// the next one in loc.
+func FiscalYear(year int, loc *time.Location) (start, end time.Time) {
+	return Date{Year: year, Month: Farvardin, Day: 1}.Time(loc),
+		Date{Year: year + 1, Month: Farvardin, Day: 1}.Time(loc)
+}
+
+// -----------------------------------------------------------------------------
+// Conversion
+// -----------------------------------------------------------------------------
+
+// breaks are the Jalali years where the leap year cycle changes.
+var breaks = [...]int{
+	-61, 9, 38, 199, 426, 686, 756, 818, 1111, 1181, 1210,
+	1635, 2060, 2097, 2192, 2262, 2324, 2394, 2456, 3178,
+}
+
+type yearInfo struct {
+	leap  int // Years since the last leap year; zero for leap years.
+	gy    int // Gregorian year of the beginning of the Jalali year.
+	march int // Day in March of Farvardin 1.
+}
+
+// calendar computes the leap state of year and where it begins in the
+// Gregorian calendar. It reports false if year is out of the supported range.
+func calendar(year int) (yearInfo, bool) {
+	if year < breaks[0] || year >= breaks[len(breaks)-1] {
+		return yearInfo{}, false
+	}
+
+	gy := year + 621
+	leapJ := -14
+	jp := breaks[0]
+	jump := 0
+	for _, jm := range breaks[1:] {
+		jump = jm - jp
+		if year < jm {
+			break
+		}
+		leapJ += jump/33*8 + jump%33/4
+		jp = jm
+	}
+	n := year - jp
+
+	leapJ += n/33*8 + (n%33+3)/4
+	if jump%33 == 4 && jump-n == 4 {
+		leapJ++
+	}
+	leapG := gy/4 - (gy/100+1)*3/4 - 150
+	march := 20 + leapJ - leapG
+
+	if jump-n < 6 {
+		n = n - jump + (jump+4)/33*33
+	}
+	leap := ((n+1)%33 - 1) % 4
+	if leap == -1 {
+		leap = 4
+	}
+	return yearInfo{leap: leap, gy: gy, march: march}, true
+}
+
+func fromJDN(jdn int) Date {
+	gy, _, _ := jdnToGregorian(jdn)
+	jy := gy - 621
+	c, _ := calendar(jy)
+	k := jdn - gregorianToJDN(gy, 3, c.march)
+	if k >= 0 {
+		if k <= 185 {
+			return Date{Year: jy, Month: Month(1 + k/31), Day: k%31 + 1}
+		}
+		k -= 186
+	} else {
+		jy--
+		k += 179
+		if c.leap == 1 {
+			k++
+		}
+	}
+	return Date{Year: jy, Month: Month(7 + k/30), Day: k%30 + 1}
+}
+
+// gregorianToJDN returns the Julian Day Number of a Gregorian date.
+func gregorianToJDN(gy, gm, gd int) int {
+	d := (gy+(gm-8)/6+100100)*1461/4 + (153*((gm+9)%12)+2)/5 + gd - 34840408
+	return d - (gy+100100+(gm-8)/6)/100*3/4 + 752
+}
+
+// jdnToGregorian returns the Gregorian date of a Julian Day Number.
+func jdnToGregorian(jdn int) (gy, gm, gd int) {
+	j := 4*jdn + 139361631
+	j += (4*jdn+183187720)/146097*3/4*4 - 3908
+	i := j%1461/4*5 + 308
+	gd = i%153/5 + 1
+	gm = i/153%12 + 1
+	gy = j/1461 - 100100 + (8-gm)/6
+	return gy, gm, gd
+}
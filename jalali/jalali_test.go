@@ -0,0 +1,155 @@
+package jalali_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // Asia/Tehran without a system time zone database.
+
+	"github.com/wallexchange/wallex-go/jalali"
+)
+
+func TestConversion(t *testing.T) {
+	tests := []struct {
+		jalali    jalali.Date
+		gregorian string
+	}{
+		{jalali.Date{Year: 1348, Month: jalali.Dey, Day: 11}, "1970-01-01"},
+		{jalali.Date{Year: 1357, Month: jalali.Bahman, Day: 22}, "1979-02-11"},
+		{jalali.Date{Year: 1358, Month: jalali.Farvardin, Day: 1}, "1979-03-21"},
+		{jalali.Date{Year: 1390, Month: jalali.Farvardin, Day: 2}, "2011-03-22"},
+		{jalali.Date{Year: 1399, Month: jalali.Esfand, Day: 30}, "2021-03-20"},
+		{jalali.Date{Year: 1402, Month: jalali.Farvardin, Day: 1}, "2023-03-21"},
+		{jalali.Date{Year: 1402, Month: jalali.Esfand, Day: 29}, "2024-03-19"},
+		{jalali.Date{Year: 1403, Month: jalali.Farvardin, Day: 1}, "2024-03-20"},
+		{jalali.Date{Year: 1403, Month: jalali.Shahrivar, Day: 31}, "2024-09-21"},
+		{jalali.Date{Year: 1403, Month: jalali.Mehr, Day: 1}, "2024-09-22"},
+		{jalali.Date{Year: 1403, Month: jalali.Esfand, Day: 30}, "2025-03-20"},
+		{jalali.Date{Year: 1404, Month: jalali.Farvardin, Day: 1}, "2025-03-21"},
+	}
+	for _, tt := range tests {
+		g, err := time.Parse("2006-01-02", tt.gregorian)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := jalali.FromTime(g); d != tt.jalali {
+			t.Errorf("FromTime(%s) = %v, want %v", tt.gregorian, d, tt.jalali)
+		}
+		if got := tt.jalali.Time(time.UTC); !got.Equal(g) {
+			t.Errorf("%v.Time() = %v, want %s", tt.jalali, got, tt.gregorian)
+		}
+	}
+}
+
+func TestLeapYears(t *testing.T) {
+	for _, y := range []int{1370, 1375, 1379, 1383, 1387, 1391, 1395, 1399, 1403, 1408} {
+		if !jalali.IsLeap(y) {
+			t.Errorf("%d is not leap", y)
+		}
+		if _, err := jalali.NewDate(y, jalali.Esfand, 30); err != nil {
+			t.Errorf("%d/12/30: %v", y, err)
+		}
+	}
+	for _, y := range []int{1400, 1401, 1402, 1404, 1405} {
+		if jalali.IsLeap(y) {
+			t.Errorf("%d is leap", y)
+		}
+		if _, err := jalali.NewDate(y, jalali.Esfand, 30); err == nil {
+			t.Errorf("%d/12/30 is accepted", y)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tehran := jalali.Tehran()
+	tests := []struct {
+		layout, value string
+		want          time.Time
+	}{
+		{"%Y/%m/%d", "1403/12/30", time.Date(2025, 3, 20, 0, 0, 0, 0, tehran)},
+		{"%Y-%m-%d %H:%M:%S", "1402-07-15 08:05:09", time.Date(2023, 10, 7, 8, 5, 9, 0, tehran)},
+		{"%y%m%d", "030101", time.Date(2024, 3, 20, 0, 0, 0, 0, tehran)},
+		{"%Y/%m/%d", "۱۴۰۳/۰۱/۰۱", time.Date(2024, 3, 20, 0, 0, 0, 0, tehran)},
+		{"%d%% %Y/%m", "01% 1403/01", time.Date(2024, 3, 20, 0, 0, 0, 0, tehran)},
+		// Days of daylight saving transitions.
+		{"%Y/%m/%d %H:%M", "1390/01/02 12:00", time.Date(2011, 3, 22, 12, 0, 0, 0, tehran)},
+		{"%Y/%m/%d %H:%M", "1390/06/30 12:00", time.Date(2011, 9, 21, 12, 0, 0, 0, tehran)},
+	}
+	for _, tt := range tests {
+		got, err := jalali.Parse(tt.layout, tt.value, tehran)
+		if err != nil {
+			t.Errorf("Parse(%q, %q): %v", tt.layout, tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("Parse(%q, %q) = %v, want %v", tt.layout, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		layout, value string
+	}{
+		{"%Y/%m/%d", "1402/12/30"}, // Not a leap year.
+		{"%Y/%m/%d", "1402/13/01"},
+		{"%Y/%m/%d", "1402/00/01"},
+		{"%Y/%m/%d", "1402/07/31"},
+		{"%Y/%m/%d", "3200/01/01"},
+		{"%Y/%m/%d %H:%M", "1402/01/01 24:00"},
+		{"%Y/%m/%d %H:%M", "1402/01/01 12:60"},
+		{"%Y/%m/%d", "1402/01/01 "},
+		{"%Y/%m/%d", "1402-01-01"},
+		{"%Y/%m/%d", "1402/01/"},
+		{"%Y/%m/%d", ""},
+		{"%Y %q", "1402 x"},
+	}
+	for _, tt := range tests {
+		if got, err := jalali.Parse(tt.layout, tt.value, time.UTC); err == nil {
+			t.Errorf("Parse(%q, %q) = %v, want an error", tt.layout, tt.value, got)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tm := time.Date(2024, 3, 20, 9, 5, 7, 0, jalali.Tehran())
+	tests := []struct {
+		layout, want string
+	}{
+		{"%Y/%m/%d %H:%M:%S", "1403/01/01 09:05:07"},
+		{"%y %j %z", "03 001 +0330"},
+		{"%B %b", "فروردین Farvardin"},
+		{"%A %a", "چهارشنبه Wednesday"},
+		{"100%% %q", "100% %q"},
+	}
+	for _, tt := range tests {
+		if got := jalali.Format(tm, tt.layout); got != tt.want {
+			t.Errorf("Format(%q) = %q, want %q", tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestFormatParseRoundTrip(t *testing.T) {
+	const layout = "%Y/%m/%d %H:%M:%S"
+	tehran := jalali.Tehran()
+	// The days clocks moved forward and back in 1390.
+	for _, day := range []time.Time{
+		time.Date(2011, 3, 22, 0, 0, 0, 0, tehran),
+		time.Date(2011, 9, 21, 0, 0, 0, 0, tehran),
+	} {
+		for tm := day; tm.Day() == day.Day(); tm = tm.Add(15 * time.Minute) {
+			s := jalali.Format(tm, layout)
+			got, err := jalali.Parse(layout, s, tehran)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", s, err)
+			}
+			if jalali.Format(got, layout) != s {
+				t.Errorf("Parse(%q) = %v, formatted as %q", s, got, jalali.Format(got, layout))
+			}
+			// The repeated hour after clocks move back cannot be told apart
+			// without an offset.
+			if !got.Equal(tm) && !got.Equal(tm.Add(-time.Hour)) && !got.Equal(tm.Add(time.Hour)) {
+				t.Errorf("Parse(%q) = %v, want %v", s, got, tm)
+			}
+		}
+	}
+}
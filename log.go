@@ -0,0 +1,79 @@
+package wallex
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/wallexchange/wallex-go/internal/redact"
+)
+
+// LogEvent describes a completed request attempt.
+type LogEvent struct {
+	Endpoint string
+	Method   string
+	URL      string
+	Attempt  int
+	Status   int
+	Latency  time.Duration
+
+	// Header holds the request headers with the API key redacted.
+	Header http.Header
+
+	// Body holds the response body with personal information redacted,
+	// if ClientOptions.LogBodies is set.
+	Body []byte
+
+	// Err is the error of the attempt, if any. It is usually an *Error.
+	Err error
+}
+
+// String formats e as space-separated key=value pairs.
+func (e *LogEvent) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "endpoint=%s method=%s url=%q attempt=%d status=%d latency=%s",
+		e.Endpoint, e.Method, e.URL, e.Attempt, e.Status, e.Latency)
+	if e.Err != nil {
+		fmt.Fprintf(&b, " error=%q", e.Err.Error())
+	}
+	if e.Body != nil {
+		fmt.Fprintf(&b, " body=%q", e.Body)
+	}
+	return b.String()
+}
+
+// Logger receives log events of a Client.
+type Logger interface {
+	Log(e *LogEvent)
+}
+
+// LoggerFunc is an adapter to allow the use of ordinary functions as Logger.
+type LoggerFunc func(e *LogEvent)
+
+// Log calls f(e).
+func (f LoggerFunc) Log(e *LogEvent) {
+	f(e)
+}
+
+func (c *Client) log(r *request, attempt int, req *http.Request, resp *http.Response, body []byte, latency time.Duration, err error) {
+	e := &LogEvent{
+		Endpoint: r.endpoint,
+		Method:   r.method,
+		URL:      r.path,
+		Attempt:  attempt,
+		Latency:  latency,
+		Err:      err,
+	}
+	if req != nil {
+		e.URL = req.URL.RequestURI()
+		e.Header = redact.Header(req.Header, apiKeyHeader)
+	}
+	if resp != nil {
+		e.Status = resp.StatusCode
+	}
+	if body != nil {
+		e.Body = redact.JSON(body)
+	}
+	c.logger.Log(e)
+}
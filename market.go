@@ -0,0 +1,92 @@
+package wallex
+
+// ErrInvalidOrder is returned when order params violate market rules.
+var ErrInvalidOrder = &Error{Message: "invalid order"}
+
+// PricePrecision returns the number of decimal places accepted in prices.
+func (m *Market) PricePrecision() int {
+	if m.TickSize < 0 {
+		return 0
+	}
+	return m.TickSize
+}
+
+// QuantityPrecision returns the number of decimal places accepted in
+// quantities.
+func (m *Market) QuantityPrecision() int {
+	if m.StepSize < 0 {
+		return 0
+	}
+	return m.StepSize
+}
+
+// RoundPrice rounds p to the price precision of m using mode,
+// e.g. RoundFloor, RoundCeil or RoundHalfUp for the nearest price.
+// The result is undefined if p is not a valid number.
+func (m *Market) RoundPrice(p Number, mode RoundingMode) Number {
+	return p.Round(m.PricePrecision(), mode)
+}
+
+// RoundQuantity rounds q to the quantity precision of m using mode,
+// e.g. RoundFloor, RoundCeil or RoundHalfUp for the nearest quantity.
+// The result is undefined if q is not a valid number.
+func (m *Market) RoundQuantity(q Number, mode RoundingMode) Number {
+	return q.Round(m.QuantityPrecision(), mode)
+}
+
+// QuantityForNotional returns the quantity worth notional in quote asset at
+// price p, rounded to the quantity precision of m using mode. RoundFloor
+// never exceeds notional, while RoundCeil never falls short of it.
+// The result is undefined if either argument is not a valid number or p is
+// zero.
+func (m *Market) QuantityForNotional(notional, p Number, mode RoundingMode) Number {
+	return notional.Div(p, m.QuantityPrecision(), mode)
+}
+
+// ValidateOrder checks p against the precision, minimum quantity and minimum
+// notional of m. The returned error matches ErrInvalidOrder and lists the
+// violations per field. Market orders are checked only for their quantity.
+func (m *Market) ValidateOrder(p *OrderParams) error {
+	fields := map[string][]string{}
+	if p.Symbol != m.Symbol {
+		fields["symbol"] = append(fields["symbol"], "does not match market "+m.Symbol)
+	}
+
+	q, qok := p.Quantity.Rat()
+	switch {
+	case !qok || q.Sign() <= 0:
+		fields["quantity"] = append(fields["quantity"], "must be a positive number")
+	default:
+		if m.RoundQuantity(p.Quantity, RoundDown).Cmp(p.Quantity) != 0 {
+			fields["quantity"] = append(fields["quantity"], "exceeds quantity precision")
+		}
+		if minQty, ok := m.MinQty.Rat(); ok && q.Cmp(minQty) < 0 {
+			fields["quantity"] = append(fields["quantity"], "is less than minimum quantity "+string(m.MinQty))
+		}
+	}
+
+	if p.Type != OrderTypeMarket {
+		pr, pok := p.Price.Rat()
+		switch {
+		case !pok || pr.Sign() <= 0:
+			fields["price"] = append(fields["price"], "must be a positive number")
+		default:
+			if m.RoundPrice(p.Price, RoundDown).Cmp(p.Price) != 0 {
+				fields["price"] = append(fields["price"], "exceeds price precision")
+			}
+			notional := p.Price.Mul(p.Quantity)
+			if !notional.IsUndefined() && !m.MinNotional.IsUndefined() && notional.Cmp(m.MinNotional) < 0 {
+				fields["price"] = append(fields["price"], "notional is less than minimum notional "+string(m.MinNotional))
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return &Error{
+		Message: ErrInvalidOrder.Message,
+		Fields:  fields,
+		kind:    ErrInvalidOrder,
+	}
+}
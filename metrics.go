@@ -0,0 +1,230 @@
+package wallex
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the default upper bounds, in seconds,
+// of the request latency histogram.
+var DefaultLatencyBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics collects statistics of API calls: request counts, latencies and
+// errors per endpoint, and waits of the rate limiter. It serves them over
+// HTTP in Prometheus text format. It is safe for concurrent use and may be
+// shared between clients.
+type Metrics struct {
+	mu       sync.Mutex
+	buckets  []float64
+	requests map[[2]string]uint64 // endpoint, status
+	errors   map[[2]string]uint64 // endpoint, kind
+	latency  map[string]*histogram
+	waits    map[RateGroup]*histogram
+	rejects  map[RateGroup]uint64
+}
+
+// NewMetrics instantiates a new Metrics using the given latency buckets.
+// If no buckets are given, DefaultLatencyBuckets is used.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		buckets:  buckets,
+		requests: map[[2]string]uint64{},
+		errors:   map[[2]string]uint64{},
+		latency:  map[string]*histogram{},
+		waits:    map[RateGroup]*histogram{},
+		rejects:  map[RateGroup]uint64{},
+	}
+}
+
+// observeRequest records a completed request attempt.
+func (m *Metrics) observeRequest(endpoint string, status int, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code := "none"
+	if status != 0 {
+		code = strconv.Itoa(status)
+	}
+	m.requests[[2]string{endpoint, code}]++
+	h, ok := m.latency[endpoint]
+	if !ok {
+		h = newHistogram(m.buckets)
+		m.latency[endpoint] = h
+	}
+	h.observe(latency.Seconds())
+	if err != nil {
+		m.errors[[2]string{endpoint, errorKind(err)}]++
+	}
+}
+
+// observeRateLimit records a rate limiter wait or rejection.
+func (m *Metrics) observeRateLimit(g RateGroup, wait time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		if err == ErrRateLimited {
+			m.rejects[g]++
+		}
+		return
+	}
+	h, ok := m.waits[g]
+	if !ok {
+		h = newHistogram(m.buckets)
+		m.waits[g] = h
+	}
+	h.observe(wait.Seconds())
+}
+
+// ServeHTTP writes the metrics in Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	b.WriteString("# HELP wallex_requests_total Number of requests sent to Wallex API.\n")
+	b.WriteString("# TYPE wallex_requests_total counter\n")
+	for _, k := range sortedPairs(m.requests) {
+		fmt.Fprintf(&b, "wallex_requests_total{endpoint=%s,status=%s} %d\n",
+			quoteLabel(k[0]), quoteLabel(k[1]), m.requests[k])
+	}
+
+	b.WriteString("# HELP wallex_request_duration_seconds Latency of requests sent to Wallex API.\n")
+	b.WriteString("# TYPE wallex_request_duration_seconds histogram\n")
+	endpoints := make([]string, 0, len(m.latency))
+	for e := range m.latency {
+		endpoints = append(endpoints, e)
+	}
+	sort.Strings(endpoints)
+	for _, e := range endpoints {
+		m.latency[e].write(&b, "wallex_request_duration_seconds", "endpoint="+quoteLabel(e))
+	}
+
+	b.WriteString("# HELP wallex_errors_total Number of failed requests by error kind.\n")
+	b.WriteString("# TYPE wallex_errors_total counter\n")
+	for _, k := range sortedPairs(m.errors) {
+		fmt.Fprintf(&b, "wallex_errors_total{endpoint=%s,kind=%s} %d\n",
+			quoteLabel(k[0]), quoteLabel(k[1]), m.errors[k])
+	}
+
+	b.WriteString("# HELP wallex_rate_limit_wait_seconds Time spent waiting for the client rate limiter.\n")
+	b.WriteString("# TYPE wallex_rate_limit_wait_seconds histogram\n")
+	for g := RateGroupPublic; g <= RateGroupOrder; g++ {
+		if h, ok := m.waits[g]; ok {
+			h.write(&b, "wallex_rate_limit_wait_seconds", "group="+quoteLabel(g.String()))
+		}
+	}
+
+	b.WriteString("# HELP wallex_rate_limit_rejections_total Number of requests rejected by the client rate limiter.\n")
+	b.WriteString("# TYPE wallex_rate_limit_rejections_total counter\n")
+	for g := RateGroupPublic; g <= RateGroupOrder; g++ {
+		if n, ok := m.rejects[g]; ok {
+			fmt.Fprintf(&b, "wallex_rate_limit_rejections_total{group=%s} %d\n", quoteLabel(g.String()), n)
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// histogram is a cumulative Prometheus histogram.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) write(b *strings.Builder, name, labels string) {
+	for i, bound := range h.bounds {
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n",
+			name, labels, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+func sortedPairs(m map[[2]string]uint64) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+// errorKinds names the sentinel errors in metrics.
+var errorKinds = []struct {
+	err  *Error
+	kind string
+}{
+	{ErrMissingAPIKey, "missing_api_key"},
+	{ErrBadRequest, "bad_request"},
+	{ErrUnauthorized, "unauthorized"},
+	{ErrForbidden, "forbidden"},
+	{ErrNotFound, "not_found"},
+	{ErrTooManyRequests, "too_many_requests"},
+	{ErrServer, "server_error"},
+	{ErrRateLimited, "rate_limited"},
+	{ErrInvalidOrder, "invalid_order"},
+	{ErrInvalidNumber, "invalid_number"},
+	{ErrUnknown, "unknown"},
+}
+
+// errorKind returns a short name for the kind of err.
+func errorKind(err error) string {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	return "request_failed"
+}
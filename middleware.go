@@ -0,0 +1,37 @@
+package wallex
+
+import (
+	"net/http"
+)
+
+// Call is an outgoing HTTP request to Wallex API.
+type Call struct {
+
+	// Endpoint is the name of the client method, e.g. "PlaceOrder".
+	Endpoint string
+
+	// Attempt is the 1-based attempt number of the request.
+	Attempt int
+
+	// Request is the HTTP request to send. Middleware may modify it
+	// or replace it before passing the call on.
+	Request *http.Request
+}
+
+// Handler sends a call and returns its response.
+type Handler func(call *Call) (*http.Response, error)
+
+// Middleware wraps a Handler to add behavior to every call,
+// such as logging, tracing or header injection.
+type Middleware func(next Handler) Handler
+
+// chain wraps h with middleware, the first one being the outermost.
+func chain(h func(*http.Request) (*http.Response, error), middleware []Middleware) Handler {
+	next := Handler(func(call *Call) (*http.Response, error) {
+		return h(call.Request)
+	})
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i](next)
+	}
+	return next
+}
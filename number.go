@@ -1,9 +1,15 @@
 package wallex
 
 import (
+	"bytes"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
+	"reflect"
 	"strconv"
+	"strings"
 )
 
 // Number represents a number or a number string.
@@ -20,20 +26,656 @@ func (n Number) Float() float64 {
 	return f
 }
 
-// UnmarshalJSON deserializes n from JSON data.
+// UnmarshalJSON deserializes n from a JSON number or string, keeping its
+// exact literal. JSON null leaves n unchanged.
 func (n *Number) UnmarshalJSON(data []byte) error {
-	*n = ""
-	var s string
-	if err := json.Unmarshal(data, &s); err == nil {
-		if _, err := strconv.ParseFloat(s, 64); err == nil {
-			*n = Number(s)
+	data = bytes.TrimSpace(data)
+	switch {
+	case string(data) == "null":
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return n.UnmarshalText([]byte(s))
+	default:
+		return n.set(string(data))
+	}
+}
+
+// MarshalJSON serializes n into a JSON string, keeping its exact literal.
+func (n Number) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(n))
+}
+
+// UnmarshalText deserializes n from text, keeping its exact literal.
+func (n *Number) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*n = ""
+		return nil
+	}
+	return n.set(string(text))
+}
+
+// MarshalText serializes n into text, keeping its exact literal.
+func (n Number) MarshalText() ([]byte, error) {
+	return []byte(n), nil
+}
+
+// set sets n to s if it is a valid number, or leaves it undefined.
+// Use DecodeStrict to report invalid numbers instead.
+func (n *Number) set(s string) error {
+	if isDecimal(s) {
+		*n = Number(s)
+	} else {
+		*n = ""
+	}
+	return nil
+}
+
+// DecodeStrict is like json.Unmarshal, but fails if a Number or NullNumber
+// in v is given a value that is not a valid number, rather than leaving it
+// undefined. Empty strings and JSON null are never considered invalid.
+// The error matches ErrInvalidNumber and names the offending field.
+func DecodeStrict(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return err
+	}
+	return checkNumbers(reflect.TypeOf(v), tree, "")
+}
+
+var (
+	numberType      = reflect.TypeOf(Number(""))
+	nullNumberType  = reflect.TypeOf(NullNumber{})
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// checkNumbers reports the first invalid number of the decoded JSON value
+// raw, where it is decoded into a value of type t.
+func checkNumbers(t reflect.Type, raw interface{}, path string) error {
+	if raw == nil || t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == numberType || t == nullNumberType {
+		var s string
+		switch v := raw.(type) {
+		case string:
+			s = v
+		case json.Number:
+			s = string(v)
+		default:
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		if s == "" || isDecimal(s) {
+			return nil
 		}
+		if path == "" {
+			return invalidNumberError(s)
+		}
+		return &Error{
+			Message: fmt.Sprintf("invalid number %q in %s", s, path),
+			kind:    ErrInvalidNumber,
+		}
+	}
+	if t.Kind() != reflect.Struct && reflect.PtrTo(t).Implements(unmarshalerType) {
+		// Decoded by its own rules.
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elems, _ := raw.([]interface{})
+		for i, e := range elems {
+			if err := checkNumbers(t.Elem(), e, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		obj, _ := raw.(map[string]interface{})
+		for k, e := range obj {
+			if err := checkNumbers(t.Elem(), e, joinPath(path, k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || (f.PkgPath != "" && !f.Anonymous) {
+				continue
+			}
+			if f.Anonymous && name == "" {
+				// Fields of embedded structs are promoted.
+				if err := checkNumbers(f.Type, raw, path); err != nil {
+					return err
+				}
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if err := checkNumbers(f.Type, lookupField(obj, name), joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookupField returns the value of key in obj, matched case-insensitively
+// like encoding/json does if there is no exact match.
+func lookupField(obj map[string]interface{}, key string) interface{} {
+	if v, ok := obj[key]; ok {
+		return v
+	}
+	for k, v := range obj {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func invalidNumberError(s string) error {
+	return &Error{
+		Message: fmt.Sprintf("invalid number %q", s),
+		kind:    ErrInvalidNumber,
+	}
+}
+
+// NullNumber is a Number that tells apart missing, null and invalid values
+// when decoded from JSON.
+type NullNumber struct {
+	Number Number
+
+	// Present is true if the value was present, including as null.
+	Present bool
+
+	// Null is true if the value was JSON null.
+	Null bool
+
+	// Valid is true if the value was a valid number.
+	Valid bool
+}
+
+// UnmarshalJSON deserializes n from JSON data.
+func (n *NullNumber) UnmarshalJSON(data []byte) error {
+	*n = NullNumber{Present: true}
+	if string(bytes.TrimSpace(data)) == "null" {
+		n.Null = true
 		return nil
 	}
-	var f float64
-	if err := json.Unmarshal(data, &f); err == nil {
-		*n = Number(fmt.Sprintf("%f", f))
+	if err := n.Number.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	n.Valid = isDecimal(string(n.Number))
+	return nil
+}
+
+// MarshalJSON serializes n into JSON data.
+// Values that are not valid numbers are serialized as null.
+func (n NullNumber) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return n.Number.MarshalJSON()
+}
+
+// Scan implements sql.Scanner. NULL scans into an undefined Number, and
+// values that are not valid numbers fail.
+func (n *Number) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*n = ""
+	case string:
+		return n.scanString(v)
+	case []byte:
+		return n.scanString(string(v))
+	case int64:
+		*n = NumberFromInt64(v)
+	case float64:
+		*n = Number(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return &Error{
+			Message: fmt.Sprintf("cannot scan %T into Number", src),
+			kind:    ErrInvalidNumber,
+		}
+	}
+	return nil
+}
+
+func (n *Number) scanString(s string) error {
+	if s == "" {
+		*n = ""
 		return nil
 	}
+	if !isDecimal(s) {
+		return invalidNumberError(s)
+	}
+	*n = Number(s)
 	return nil
 }
+
+// Value implements driver.Valuer. The exact literal of n is stored as a
+// string, so it survives DECIMAL and text columns without loss.
+// An undefined Number is stored as NULL.
+func (n Number) Value() (driver.Value, error) {
+	if n == "" {
+		return nil, nil
+	}
+	if !isDecimal(string(n)) {
+		return nil, invalidNumberError(string(n))
+	}
+	return string(n), nil
+}
+
+// Format implements fmt.Formatter. The verbs %s, %v and %q print the exact
+// literal of n, while %f rounds it exactly to the given precision (6 by
+// default) and %d to an integer, without converting to float.
+// Other verbs format n.Float().
+func (n Number) Format(f fmt.State, verb rune) {
+	var s string
+	switch verb {
+	case 'v':
+		if f.Flag('#') {
+			s = fmt.Sprintf("wallex.Number(%q)", string(n))
+			break
+		}
+		s = string(n)
+	case 's':
+		s = string(n)
+	case 'q':
+		s = strconv.Quote(string(n))
+	case 'f', 'F', 'd':
+		prec, ok := f.Precision()
+		if !ok {
+			prec = 6
+		}
+		if verb == 'd' {
+			prec = 0
+		}
+		s = string(n.Round(prec, RoundHalfEven))
+		if s == "" {
+			fmt.Fprintf(f, "%%!%c(wallex.Number=%s)", verb, string(n))
+			return
+		}
+		if f.Flag('+') && !strings.HasPrefix(s, "-") {
+			s = "+" + s
+		}
+	default:
+		fmt.Fprintf(f, formatDirective(f, verb), n.Float())
+		return
+	}
+
+	width, ok := f.Width()
+	if !ok || len(s) >= width {
+		io.WriteString(f, s)
+		return
+	}
+	pad := strings.Repeat(" ", width-len(s))
+	if f.Flag('-') {
+		io.WriteString(f, s+pad)
+	} else {
+		io.WriteString(f, pad+s)
+	}
+}
+
+// formatDirective reconstructs the directive that f was created from.
+func formatDirective(f fmt.State, verb rune) string {
+	var b strings.Builder
+	b.WriteByte('%')
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			b.WriteRune(flag)
+		}
+	}
+	if w, ok := f.Width(); ok {
+		b.WriteString(strconv.Itoa(w))
+	}
+	if p, ok := f.Precision(); ok {
+		b.WriteByte('.')
+		b.WriteString(strconv.Itoa(p))
+	}
+	b.WriteRune(verb)
+	return b.String()
+}
+
+// Scan implements sql.Scanner.
+func (n *NullNumber) Scan(src interface{}) error {
+	*n = NullNumber{Present: true}
+	if src == nil {
+		n.Null = true
+		return nil
+	}
+	if err := n.Number.Scan(src); err != nil {
+		return err
+	}
+	n.Valid = n.Number != ""
+	return nil
+}
+
+// Value implements driver.Valuer.
+// Values that are not valid numbers are stored as NULL.
+func (n NullNumber) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Number.Value()
+}
+
+// RoundingMode specifies how a Number is rounded to a given scale.
+type RoundingMode int
+
+// List of rounding modes.
+const (
+	RoundHalfUp   RoundingMode = iota // To nearest, ties away from zero.
+	RoundHalfEven                     // To nearest, ties to even.
+	RoundDown                         // Toward zero.
+	RoundUp                           // Away from zero.
+	RoundFloor                        // Toward negative infinity.
+	RoundCeil                         // Toward positive infinity.
+)
+
+// ErrInvalidNumber is returned when a string is not a valid decimal number.
+var ErrInvalidNumber = &Error{Message: "invalid number"}
+
+// NumberFromInt64 returns the Number of i.
+func NumberFromInt64(i int64) Number {
+	return Number(strconv.FormatInt(i, 10))
+}
+
+// ParseNumber validates s as a decimal number, such as "-12.5" or "1e-8",
+// and returns it as a Number with its literal preserved.
+func ParseNumber(s string) (Number, error) {
+	if !isDecimal(s) {
+		return "", invalidNumberError(s)
+	}
+	return Number(s), nil
+}
+
+// NumberFromRat returns r rounded to scale fractional digits using mode.
+// A negative scale is treated as zero.
+func NumberFromRat(r *big.Rat, scale int, mode RoundingMode) Number {
+	if scale < 0 {
+		scale = 0
+	}
+	return formatScaled(roundRat(r, scale, mode), scale)
+}
+
+// Rat returns the exact value of n.
+// It reports false if n is undefined or not a valid number.
+func (n Number) Rat() (*big.Rat, bool) {
+	if !isDecimal(string(n)) {
+		return nil, false
+	}
+	r, ok := new(big.Rat).SetString(string(n))
+	return r, ok
+}
+
+// Add returns the exact sum n+m.
+// The result is undefined if either operand is not a valid number.
+func (n Number) Add(m Number) Number {
+	return n.binary(m, (*big.Rat).Add)
+}
+
+// Sub returns the exact difference n-m.
+// The result is undefined if either operand is not a valid number.
+func (n Number) Sub(m Number) Number {
+	return n.binary(m, (*big.Rat).Sub)
+}
+
+// Mul returns the exact product n*m.
+// The result is undefined if either operand is not a valid number.
+func (n Number) Mul(m Number) Number {
+	return n.binary(m, (*big.Rat).Mul)
+}
+
+// Div returns the quotient n/m rounded to scale fractional digits using mode.
+// The result is undefined if either operand is not a valid number or m is zero.
+func (n Number) Div(m Number, scale int, mode RoundingMode) Number {
+	x, ok := n.Rat()
+	if !ok {
+		return ""
+	}
+	y, ok := m.Rat()
+	if !ok || y.Sign() == 0 {
+		return ""
+	}
+	return NumberFromRat(x.Quo(x, y), scale, mode)
+}
+
+// Round returns n rounded to exactly scale fractional digits using mode.
+// The result is undefined if n is not a valid number.
+func (n Number) Round(scale int, mode RoundingMode) Number {
+	r, ok := n.Rat()
+	if !ok {
+		return ""
+	}
+	return NumberFromRat(r, scale, mode)
+}
+
+// Cmp compares n and m and returns -1, 0 or +1 if n is less than, equal to
+// or greater than m. Undefined numbers are equal to each other and less than
+// any valid number.
+func (n Number) Cmp(m Number) int {
+	x, okx := n.Rat()
+	y, oky := m.Rat()
+	switch {
+	case okx && oky:
+		return x.Cmp(y)
+	case okx:
+		return 1
+	case oky:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// Neg returns -n, keeping the scale of n.
+// The result is undefined if n is not a valid number.
+func (n Number) Neg() Number {
+	r, ok := n.Rat()
+	if !ok {
+		return ""
+	}
+	s := string(n)
+	switch {
+	case r.Sign() == 0:
+		return n.Abs()
+	case s[0] == '-':
+		return Number(s[1:])
+	case s[0] == '+':
+		return Number("-" + s[1:])
+	default:
+		return Number("-" + s)
+	}
+}
+
+// Abs returns the absolute value of n, keeping the scale of n.
+// The result is undefined if n is not a valid number.
+func (n Number) Abs() Number {
+	if !isDecimal(string(n)) {
+		return ""
+	}
+	return Number(strings.TrimLeft(string(n), "+-"))
+}
+
+// IsZero reports whether n is a valid number equal to zero.
+func (n Number) IsZero() bool {
+	r, ok := n.Rat()
+	return ok && r.Sign() == 0
+}
+
+func (n Number) binary(m Number, op func(z, x, y *big.Rat) *big.Rat) Number {
+	x, ok := n.Rat()
+	if !ok {
+		return ""
+	}
+	y, ok := m.Rat()
+	if !ok {
+		return ""
+	}
+	return formatExact(op(x, x, y))
+}
+
+// maxExponent is the largest exponent accepted in a Number.
+const maxExponent = 1000
+
+// isDecimal reports whether s is a decimal number with an optional sign,
+// fraction and exponent.
+func isDecimal(s string) bool {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		start := i
+		for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+		}
+		// Huge exponents are rejected, as they would make exact
+		// arithmetic arbitrarily expensive.
+		if exp, err := strconv.Atoi(s[start:i]); err != nil || exp > maxExponent {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+var (
+	bigOne = big.NewInt(1)
+	bigTwo = big.NewInt(2)
+	bigTen = big.NewInt(10)
+)
+
+// formatExact formats r, which must have a finite decimal expansion,
+// with the fewest fractional digits.
+func formatExact(r *big.Rat) Number {
+	// The denominator is of the form 2^a * 5^b, so max(a, b) fractional
+	// digits are enough to represent r exactly.
+	d := new(big.Int).Set(r.Denom())
+	twos, fives := 0, 0
+	m := new(big.Int)
+	for d.Bit(0) == 0 {
+		d.Rsh(d, 1)
+		twos++
+	}
+	five := big.NewInt(5)
+	for {
+		q, rem := new(big.Int).QuoRem(d, five, m)
+		if rem.Sign() != 0 {
+			break
+		}
+		d = q
+		fives++
+	}
+	scale := twos
+	if fives > scale {
+		scale = fives
+	}
+	if d.Cmp(bigOne) != 0 {
+		// Not a finite decimal; this cannot happen for sums, differences
+		// and products of decimals.
+		return NumberFromRat(r, 32, RoundHalfEven)
+	}
+	return formatScaled(roundRat(r, scale, RoundDown), scale)
+}
+
+// roundRat returns r * 10^scale rounded to an integer using mode.
+func roundRat(r *big.Rat, scale int, mode RoundingMode) *big.Int {
+	num := new(big.Int).Mul(r.Num(), new(big.Int).Exp(bigTen, big.NewInt(int64(scale)), nil))
+	den := r.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return q
+	}
+
+	neg := num.Sign() < 0
+	var away bool
+	switch mode {
+	case RoundDown:
+		away = false
+	case RoundUp:
+		away = true
+	case RoundFloor:
+		away = neg
+	case RoundCeil:
+		away = !neg
+	default:
+		// Compare twice the remainder with the denominator to find ties.
+		c := new(big.Int).Mul(new(big.Int).Abs(rem), bigTwo).Cmp(den)
+		switch {
+		case c > 0:
+			away = true
+		case c < 0:
+			away = false
+		case mode == RoundHalfEven:
+			away = q.Bit(0) == 1
+		default:
+			away = true
+		}
+	}
+	if away {
+		if neg {
+			q.Sub(q, bigOne)
+		} else {
+			q.Add(q, bigOne)
+		}
+	}
+	return q
+}
+
+// formatScaled formats i * 10^-scale with exactly scale fractional digits.
+func formatScaled(i *big.Int, scale int) Number {
+	neg := i.Sign() < 0
+	digits := new(big.Int).Abs(i).String()
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if neg {
+		digits = "-" + digits
+	}
+	return Number(digits)
+}
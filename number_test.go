@@ -0,0 +1,446 @@
+package wallex_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	wallex "github.com/wallexchange/wallex-go"
+)
+
+func TestFeeLevelKeysAreKeptAsIs(t *testing.T) {
+	var l wallex.FeeLevel
+	data := `{"levels":{"1":{"maker_fee":"0.1"},"vip":{"maker_fee":"0.05"},"02":{}},"maker_fee":"0.2"}`
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []wallex.Number{"1", "vip", "02"} {
+		if l.Levels[k] == nil {
+			t.Errorf("level %q is missing", k)
+		}
+	}
+	if len(l.Levels) != 3 {
+		t.Errorf("got %d levels, want 3", len(l.Levels))
+	}
+	if l.MakerFee != "0.2" {
+		t.Errorf("MakerFee = %q, want 0.2", l.MakerFee)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		data    string
+		invalid bool
+	}{
+		{`{"price":"1.50","executedQty":null,"origQty":1.5e3,"origSum":""}`, false},
+		{`{"price":"abc"}`, true},
+		{`{"executedQty":true}`, true},
+		{`{"Price":"1,000"}`, true},
+	}
+	for _, tt := range tests {
+		var o wallex.Order
+		err := wallex.DecodeStrict([]byte(tt.data), &o)
+		if got := errors.Is(err, wallex.ErrInvalidNumber); got != tt.invalid {
+			t.Errorf("DecodeStrict(%s) = %v, want invalid %v", tt.data, err, tt.invalid)
+		}
+
+		// Lenient decoding never fails on invalid numbers.
+		if err := json.Unmarshal([]byte(tt.data), &o); err != nil {
+			t.Errorf("json.Unmarshal(%s) = %v", tt.data, err)
+		}
+	}
+}
+
+func TestNumberArithmetic(t *testing.T) {
+	tests := []struct {
+		x, y            wallex.Number
+		sum, diff, prod wallex.Number
+	}{
+		{"0.1", "0.2", "0.3", "-0.1", "0.02"},
+		{"1.50", "2.25", "3.75", "-0.75", "3.375"},
+		{"-3", "1.5", "-1.5", "-4.5", "-4.5"},
+		{"1e3", "2.5E-2", "1000.025", "999.975", "25"},
+		{"+7", "-7", "0", "14", "-49"},
+		{"0.000000000000000001", "1", "1.000000000000000001", "-0.999999999999999999", "0.000000000000000001"},
+		{"123456789012345678901234567890", "1", "123456789012345678901234567891", "123456789012345678901234567889", "123456789012345678901234567890"},
+		{"1e-30", "1e30", "1000000000000000000000000000000.000000000000000000000000000001",
+			"-999999999999999999999999999999.999999999999999999999999999999", "1"},
+		{"1", "abc", "", "", ""},
+		{"", "1", "", "", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.x.Add(tt.y); got != tt.sum {
+			t.Errorf("%s + %s = %q, want %q", tt.x, tt.y, got, tt.sum)
+		}
+		if got := tt.x.Sub(tt.y); got != tt.diff {
+			t.Errorf("%s - %s = %q, want %q", tt.x, tt.y, got, tt.diff)
+		}
+		if got := tt.x.Mul(tt.y); got != tt.prod {
+			t.Errorf("%s * %s = %q, want %q", tt.x, tt.y, got, tt.prod)
+		}
+	}
+}
+
+func TestNumberLargeExponents(t *testing.T) {
+	tests := []struct {
+		s     string
+		valid bool
+	}{
+		{"1e1000", true},
+		{"1e+1000", true},
+		{"1e-1000", true},
+		{"1e1001", false},
+		{"1e-1001", false},
+		{"1e99999999999999999999", false},
+		{"1e", false},
+		{"e5", false},
+		{".5e1", true},
+		{"5.", true},
+	}
+	for _, tt := range tests {
+		_, err := wallex.ParseNumber(tt.s)
+		if valid := err == nil; valid != tt.valid {
+			t.Errorf("ParseNumber(%q) = %v, want valid %v", tt.s, err, tt.valid)
+		}
+		if !tt.valid && !errors.Is(err, wallex.ErrInvalidNumber) {
+			t.Errorf("ParseNumber(%q) = %v, want ErrInvalidNumber", tt.s, err)
+		}
+	}
+
+	// Arithmetic stays exact at the extremes.
+	tiny, huge := wallex.Number("1e-1000"), wallex.Number("1e1000")
+	if got := tiny.Mul(huge); got != "1" {
+		t.Errorf("1e-1000 * 1e1000 = %q, want 1", got)
+	}
+	if got := huge.Add("1"); len(got) != 1001 || got[0] != '1' || got[1000] != '1' {
+		t.Errorf("1e1000 + 1 = %q", got)
+	}
+	if got := tiny.Round(8, wallex.RoundHalfUp); got != "0.00000000" {
+		t.Errorf("1e-1000 rounded to 8 digits = %q, want 0.00000000", got)
+	}
+	if got := tiny.Round(8, wallex.RoundCeil); got != "0.00000001" {
+		t.Errorf("1e-1000 rounded up to 8 digits = %q, want 0.00000001", got)
+	}
+	if got := huge.Cmp("9.99e999"); got != 1 {
+		t.Errorf("Cmp(1e1000, 9.99e999) = %d, want 1", got)
+	}
+}
+
+func TestNumberRound(t *testing.T) {
+	modes := []wallex.RoundingMode{
+		wallex.RoundHalfUp, wallex.RoundHalfEven, wallex.RoundDown,
+		wallex.RoundUp, wallex.RoundFloor, wallex.RoundCeil,
+	}
+	tests := []struct {
+		n     wallex.Number
+		scale int
+		want  [6]wallex.Number // In the order of modes.
+	}{
+		{"2.5", 0, [6]wallex.Number{"3", "2", "2", "3", "2", "3"}},
+		{"3.5", 0, [6]wallex.Number{"4", "4", "3", "4", "3", "4"}},
+		{"-2.5", 0, [6]wallex.Number{"-3", "-2", "-2", "-3", "-3", "-2"}},
+		{"1.25", 1, [6]wallex.Number{"1.3", "1.2", "1.2", "1.3", "1.2", "1.3"}},
+		{"1.2501", 1, [6]wallex.Number{"1.3", "1.3", "1.2", "1.3", "1.2", "1.3"}},
+		{"-1.249", 2, [6]wallex.Number{"-1.25", "-1.25", "-1.24", "-1.25", "-1.25", "-1.24"}},
+		{"1.5", 3, [6]wallex.Number{"1.500", "1.500", "1.500", "1.500", "1.500", "1.500"}},
+		{"123.456", -1, [6]wallex.Number{"123", "123", "123", "124", "123", "124"}},
+		{"9.99", 1, [6]wallex.Number{"10.0", "10.0", "9.9", "10.0", "9.9", "10.0"}},
+		// Negative values that round to zero lose their sign.
+		{"-0.4", 0, [6]wallex.Number{"0", "0", "0", "-1", "-1", "0"}},
+		{"-0.001", 2, [6]wallex.Number{"0.00", "0.00", "0.00", "-0.01", "-0.01", "0.00"}},
+		{"-0.5", 0, [6]wallex.Number{"-1", "0", "0", "-1", "-1", "0"}},
+		{"-0", 2, [6]wallex.Number{"0.00", "0.00", "0.00", "0.00", "0.00", "0.00"}},
+		{"-0.0e5", 0, [6]wallex.Number{"0", "0", "0", "0", "0", "0"}},
+		{"abc", 2, [6]wallex.Number{}},
+	}
+	for _, tt := range tests {
+		for i, mode := range modes {
+			if got := tt.n.Round(tt.scale, mode); got != tt.want[i] {
+				t.Errorf("%s.Round(%d, %d) = %q, want %q", tt.n, tt.scale, mode, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestNumberDiv(t *testing.T) {
+	tests := []struct {
+		x, y  wallex.Number
+		scale int
+		mode  wallex.RoundingMode
+		want  wallex.Number
+	}{
+		{"1", "3", 4, wallex.RoundHalfUp, "0.3333"},
+		{"2", "3", 4, wallex.RoundHalfUp, "0.6667"},
+		{"2", "3", 4, wallex.RoundDown, "0.6666"},
+		{"-2", "3", 0, wallex.RoundFloor, "-1"},
+		{"-1", "3", 2, wallex.RoundHalfEven, "-0.33"},
+		{"-1", "300", 2, wallex.RoundHalfEven, "0.00"},
+		{"10", "4", 0, wallex.RoundHalfEven, "2"},
+		{"10", "4", 2, wallex.RoundHalfEven, "2.50"},
+		{"1", "0", 2, wallex.RoundHalfUp, ""},
+		{"1", "0.000", 2, wallex.RoundHalfUp, ""},
+		{"1", "", 2, wallex.RoundHalfUp, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.x.Div(tt.y, tt.scale, tt.mode); got != tt.want {
+			t.Errorf("%s.Div(%s, %d, %d) = %q, want %q", tt.x, tt.y, tt.scale, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestNumberSign(t *testing.T) {
+	tests := []struct {
+		n        wallex.Number
+		neg, abs wallex.Number
+		zero     bool
+	}{
+		{"1.50", "-1.50", "1.50", false},
+		{"-1.50", "1.50", "1.50", false},
+		{"+2", "-2", "2", false},
+		{"0.00", "0.00", "0.00", true},
+		{"-0.00", "0.00", "0.00", true},
+		{"", "", "", false},
+		{"abc", "", "", false},
+	}
+	for _, tt := range tests {
+		if got := tt.n.Neg(); got != tt.neg {
+			t.Errorf("%q.Neg() = %q, want %q", tt.n, got, tt.neg)
+		}
+		if got := tt.n.Abs(); got != tt.abs {
+			t.Errorf("%q.Abs() = %q, want %q", tt.n, got, tt.abs)
+		}
+		if got := tt.n.IsZero(); got != tt.zero {
+			t.Errorf("%q.IsZero() = %v, want %v", tt.n, got, tt.zero)
+		}
+	}
+}
+
+func TestNumberCmp(t *testing.T) {
+	tests := []struct {
+		x, y wallex.Number
+		want int
+	}{
+		{"1.50", "1.5", 0},
+		{"-0", "0", 0},
+		{"1e2", "99.9999", 1},
+		{"-1", "0.1", -1},
+		{"0.1", "1e-1", 0},
+		{"", "-1e100", -1},
+		{"abc", "", 0},
+		{"0", "abc", 1},
+	}
+	for _, tt := range tests {
+		if got := tt.x.Cmp(tt.y); got != tt.want {
+			t.Errorf("Cmp(%q, %q) = %d, want %d", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestNumberFromRat(t *testing.T) {
+	tests := []struct {
+		r     *big.Rat
+		scale int
+		mode  wallex.RoundingMode
+		want  wallex.Number
+	}{
+		{big.NewRat(1, 8), 3, wallex.RoundHalfEven, "0.125"},
+		{big.NewRat(1, 8), 2, wallex.RoundHalfEven, "0.12"},
+		{big.NewRat(1, 8), 2, wallex.RoundHalfUp, "0.13"},
+		{big.NewRat(-1, 8), 2, wallex.RoundHalfUp, "-0.13"},
+		{big.NewRat(-1, 3), 0, wallex.RoundHalfUp, "0"},
+		{big.NewRat(5, 1), -2, wallex.RoundHalfUp, "5"},
+	}
+	for _, tt := range tests {
+		if got := wallex.NumberFromRat(tt.r, tt.scale, tt.mode); got != tt.want {
+			t.Errorf("NumberFromRat(%v, %d, %d) = %q, want %q", tt.r, tt.scale, tt.mode, got, tt.want)
+		}
+	}
+	if got := wallex.NumberFromInt64(-9223372036854775808); got != "-9223372036854775808" {
+		t.Errorf("NumberFromInt64(MinInt64) = %q", got)
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		n      wallex.Number
+		want   string
+	}{
+		{"%s", "1.50", "1.50"},
+		{"%v", "1e-8", "1e-8"},
+		{"%q", "1.50", `"1.50"`},
+		{"%#v", "1.50", `wallex.Number("1.50")`},
+		{"%6s", "1.5", "   1.5"},
+		{"%-6v|", "1.5", "1.5   |"},
+		{"%f", "1.5", "1.500000"},
+		// Exact rounding, where float64 would print 2.67.
+		{"%.2f", "2.675", "2.68"},
+		{"%.2f", "2.665", "2.66"},
+		{"%.0f", "2.5", "2"},
+		{"%.3f", "1e-1000", "0.000"},
+		{"%.2f", "123456789012345678901234567890.125", "123456789012345678901234567890.12"},
+		{"%d", "3.5", "4"},
+		{"%d", "1e3", "1000"},
+		// Negative values that round to zero print without a sign.
+		{"%d", "-0.4", "0"},
+		{"%.2f", "-0.001", "0.00"},
+		{"%+.1f", "1.25", "+1.2"},
+		{"%+.1f", "-1.25", "-1.2"},
+		{"%8.2f", "3.14159", "    3.14"},
+		{"%-8.2f|", "3.14159", "3.14    |"},
+		{"%f", "abc", "%!f(wallex.Number=abc)"},
+		{"%d", "", "%!d(wallex.Number=)"},
+		// Other verbs format the float value.
+		{"%e", "1500", "1.500000e+03"},
+		{"%010.2e", "1500", "001.50e+03"},
+		{"%g", "0.1", "0.1"},
+		{"%x", "255", fmt.Sprintf("%x", 255.0)},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprintf(tt.format, tt.n); got != tt.want {
+			t.Errorf("Sprintf(%q, %q) = %q, want %q", tt.format, tt.n, got, tt.want)
+		}
+	}
+
+	// Numbers in structs print their literal too.
+	b := wallex.Balance{Asset: "BTC", Value: "0.10"}
+	if got := fmt.Sprintf("%+v", b); !strings.Contains(got, "Value:0.10") {
+		t.Errorf("Sprintf(%%+v) of a balance = %q, want Value:0.10", got)
+	}
+}
+
+func TestNumberScanValue(t *testing.T) {
+	var _ sql.Scanner = (*wallex.Number)(nil)
+	var _ driver.Valuer = wallex.Number("")
+
+	tests := []struct {
+		src     interface{}
+		want    wallex.Number
+		invalid bool
+	}{
+		{nil, "", false},
+		{"1.50", "1.50", false},
+		{[]byte("2e3"), "2e3", false},
+		{"", "", false},
+		{int64(-5), "-5", false},
+		{float64(0.1), "0.1", false},
+		{float64(1e21), "1000000000000000000000", false},
+		{"abc", "", true},
+		{[]byte("1,000"), "", true},
+		{true, "", true},
+	}
+	for _, tt := range tests {
+		var n wallex.Number
+		err := n.Scan(tt.src)
+		if tt.invalid {
+			if !errors.Is(err, wallex.ErrInvalidNumber) {
+				t.Errorf("Scan(%#v) = %v, want ErrInvalidNumber", tt.src, err)
+			}
+			continue
+		}
+		if err != nil || n != tt.want {
+			t.Errorf("Scan(%#v) = %q, %v, want %q", tt.src, n, err, tt.want)
+		}
+	}
+
+	values := []struct {
+		n       wallex.Number
+		want    driver.Value
+		invalid bool
+	}{
+		{"1.50", "1.50", false},
+		{"-1e-8", "-1e-8", false},
+		{"", nil, false},
+		{"abc", nil, true},
+	}
+	for _, tt := range values {
+		v, err := tt.n.Value()
+		if tt.invalid {
+			if !errors.Is(err, wallex.ErrInvalidNumber) {
+				t.Errorf("%q.Value() = %v, want ErrInvalidNumber", tt.n, err)
+			}
+			continue
+		}
+		if err != nil || v != tt.want {
+			t.Errorf("%q.Value() = %#v, %v, want %#v", tt.n, v, err, tt.want)
+		}
+		// Values scan back into the same number.
+		var n wallex.Number
+		if err := n.Scan(v); err != nil || n != tt.n {
+			t.Errorf("Scan(%q.Value()) = %q, %v", tt.n, n, err)
+		}
+	}
+}
+
+func TestNullNumberJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want wallex.NullNumber
+		out  string
+	}{
+		{`{}`, wallex.NullNumber{}, `null`},
+		{`{"n":null}`, wallex.NullNumber{Present: true, Null: true}, `null`},
+		{`{"n":""}`, wallex.NullNumber{Present: true}, `null`},
+		{`{"n":"abc"}`, wallex.NullNumber{Present: true}, `null`},
+		{`{"n":"1.50"}`, wallex.NullNumber{Number: "1.50", Present: true, Valid: true}, `"1.50"`},
+		{`{"n":2e3}`, wallex.NullNumber{Number: "2e3", Present: true, Valid: true}, `"2e3"`},
+	}
+	for _, tt := range tests {
+		var v struct {
+			N wallex.NullNumber `json:"n"`
+		}
+		if err := json.Unmarshal([]byte(tt.data), &v); err != nil {
+			t.Errorf("json.Unmarshal(%s) = %v", tt.data, err)
+			continue
+		}
+		if v.N != tt.want {
+			t.Errorf("json.Unmarshal(%s) = %+v, want %+v", tt.data, v.N, tt.want)
+		}
+		out, err := json.Marshal(v.N)
+		if err != nil || string(out) != tt.out {
+			t.Errorf("json.Marshal(%+v) = %s, %v, want %s", v.N, out, err, tt.out)
+		}
+	}
+
+	var v struct {
+		N wallex.NullNumber `json:"n"`
+	}
+	if err := wallex.DecodeStrict([]byte(`{"n":"abc"}`), &v); !errors.Is(err, wallex.ErrInvalidNumber) {
+		t.Errorf("DecodeStrict of an invalid NullNumber = %v, want ErrInvalidNumber", err)
+	}
+}
+
+func TestNullNumberScanValue(t *testing.T) {
+	tests := []struct {
+		src   interface{}
+		want  wallex.NullNumber
+		value driver.Value
+	}{
+		{nil, wallex.NullNumber{Present: true, Null: true}, nil},
+		{"", wallex.NullNumber{Present: true}, nil},
+		{"1.50", wallex.NullNumber{Number: "1.50", Present: true, Valid: true}, "1.50"},
+		{int64(7), wallex.NullNumber{Number: "7", Present: true, Valid: true}, "7"},
+	}
+	for _, tt := range tests {
+		var n wallex.NullNumber
+		if err := n.Scan(tt.src); err != nil || n != tt.want {
+			t.Errorf("Scan(%#v) = %+v, %v, want %+v", tt.src, n, err, tt.want)
+		}
+		if v, err := n.Value(); err != nil || v != tt.value {
+			t.Errorf("%+v.Value() = %#v, %v, want %#v", n, v, err, tt.value)
+		}
+	}
+
+	var n wallex.NullNumber
+	if err := n.Scan("abc"); !errors.Is(err, wallex.ErrInvalidNumber) {
+		t.Errorf("Scan(abc) = %v, want ErrInvalidNumber", err)
+	}
+	if v, err := (wallex.NullNumber{Number: "abc"}).Value(); err != nil || v != nil {
+		t.Errorf("Value of an invalid NullNumber = %#v, %v, want NULL", v, err)
+	}
+}
@@ -0,0 +1,364 @@
+package wallex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrCrossedBook is returned when the best bid of an order book is not
+// below its best ask, which means updates were missed or reordered.
+var ErrCrossedBook = &Error{Message: "order book is crossed"}
+
+// OrderBook is a locally maintained order book of a market. It is seeded
+// from a MarketOrders snapshot and kept up to date with depth events or
+// polled diffs. It is safe for concurrent use.
+type OrderBook struct {
+	symbol string
+	client *Client
+
+	mu        sync.RWMutex
+	asks      []bookLevel // Ascending by price.
+	bids      []bookLevel // Descending by price.
+	updatedAt time.Time
+}
+
+type bookLevel struct {
+	price    *big.Rat
+	quantity *big.Rat
+	order    *MarketOrder
+}
+
+// NewOrderBook returns an order book of symbol seeded with the given
+// levels, which need not be sorted. It fails if a level is invalid or the
+// book is crossed.
+func NewOrderBook(symbol string, ask, bid []*MarketOrder) (*OrderBook, error) {
+	b := &OrderBook{symbol: symbol}
+	if err := b.Reset(ask, bid); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// OrderBook fetches a snapshot of the order book of symbol. The returned
+// book can resync itself using c.
+func (c *Client) OrderBook(symbol string) (*OrderBook, error) {
+	return c.OrderBookContext(context.Background(), symbol)
+}
+
+// OrderBookContext is like OrderBook but uses ctx for the request.
+func (c *Client) OrderBookContext(ctx context.Context, symbol string) (*OrderBook, error) {
+	b := &OrderBook{symbol: symbol, client: c}
+	if err := b.Resync(ctx); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Symbol returns the market symbol of b.
+func (b *OrderBook) Symbol() string {
+	return b.symbol
+}
+
+// UpdatedAt returns the time b was last changed.
+func (b *OrderBook) UpdatedAt() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.updatedAt
+}
+
+// Reset replaces both sides of b with a snapshot. Levels with zero quantity
+// are ignored. On error, b is left unchanged.
+func (b *OrderBook) Reset(ask, bid []*MarketOrder) error {
+	asks, err := newBookSide(ask, false)
+	if err != nil {
+		return err
+	}
+	bids, err := newBookSide(bid, true)
+	if err != nil {
+		return err
+	}
+	if crossed(asks, bids) {
+		return crossedError(b.symbol)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.asks, b.bids = asks, bids
+	b.updatedAt = time.Now()
+	return nil
+}
+
+// Resync replaces the content of b with a fresh snapshot fetched using the
+// client b was created with.
+func (b *OrderBook) Resync(ctx context.Context) error {
+	if b.client == nil {
+		return &Error{Message: "order book of " + b.symbol + " has no client to resync with"}
+	}
+	ask, bid, err := b.client.MarketOrdersContext(ctx, b.symbol)
+	if err != nil {
+		return err
+	}
+	return b.Reset(ask, bid)
+}
+
+// Replace replaces one side of b, OrderSideBuy for bids or OrderSideSell
+// for asks, as depth events of the stream do. It returns an error matching
+// ErrCrossedBook if the result is crossed; b is updated anyway and should
+// be resynced.
+func (b *OrderBook) Replace(side string, levels []*MarketOrder) error {
+	buy, err := bookSide(side)
+	if err != nil {
+		return err
+	}
+	s, err := newBookSide(levels, buy)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if buy {
+		b.bids = s
+	} else {
+		b.asks = s
+	}
+	return b.touch()
+}
+
+// Update applies a diff to one side of b: each level replaces the level at
+// its price, and levels with zero quantity remove it. It returns an error
+// matching ErrCrossedBook if the result is crossed; b is updated anyway and
+// should be resynced.
+func (b *OrderBook) Update(side string, levels ...*MarketOrder) error {
+	buy, err := bookSide(side)
+	if err != nil {
+		return err
+	}
+	parsed := make([]bookLevel, 0, len(levels))
+	for _, o := range levels {
+		l, err := newBookLevel(o)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, l)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, l := range parsed {
+		if buy {
+			b.bids = updateBookSide(b.bids, l, true)
+		} else {
+			b.asks = updateBookSide(b.asks, l, false)
+		}
+	}
+	return b.touch()
+}
+
+// ApplyEvent replaces the side of b carried by a depth event.
+// Events of other symbols are ignored.
+func (b *OrderBook) ApplyEvent(ev *DepthEvent) error {
+	if ev.Symbol != b.symbol {
+		return nil
+	}
+	return b.Replace(ev.Side, ev.Orders)
+}
+
+// Sync is like ApplyEvent, but resyncs b from a fresh snapshot if the event
+// leaves it crossed.
+func (b *OrderBook) Sync(ctx context.Context, ev *DepthEvent) error {
+	err := b.ApplyEvent(ev)
+	if errors.Is(err, ErrCrossedBook) {
+		return b.Resync(ctx)
+	}
+	return err
+}
+
+// touch must be called with b.mu held.
+func (b *OrderBook) touch() error {
+	b.updatedAt = time.Now()
+	if crossed(b.asks, b.bids) {
+		return crossedError(b.symbol)
+	}
+	return nil
+}
+
+// Crossed reports whether the best bid of b is at or above its best ask.
+func (b *OrderBook) Crossed() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return crossed(b.asks, b.bids)
+}
+
+// BestBid returns the highest bid. It reports false if there are no bids.
+func (b *OrderBook) BestBid() (*MarketOrder, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids) == 0 {
+		return nil, false
+	}
+	o := *b.bids[0].order
+	return &o, true
+}
+
+// BestAsk returns the lowest ask. It reports false if there are no asks.
+func (b *OrderBook) BestAsk() (*MarketOrder, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.asks) == 0 {
+		return nil, false
+	}
+	o := *b.asks[0].order
+	return &o, true
+}
+
+// Spread returns the best ask minus the best bid.
+// It reports false if either side is empty.
+func (b *OrderBook) Spread() (Number, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.asks) == 0 || len(b.bids) == 0 {
+		return "", false
+	}
+	r := new(big.Rat).Sub(b.asks[0].price, b.bids[0].price)
+	return formatExact(r), true
+}
+
+// Mid returns the exact average of the best bid and the best ask.
+// It reports false if either side is empty.
+func (b *OrderBook) Mid() (Number, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.asks) == 0 || len(b.bids) == 0 {
+		return "", false
+	}
+	r := new(big.Rat).Add(b.asks[0].price, b.bids[0].price)
+	return formatExact(r.Quo(r, big.NewRat(2, 1))), true
+}
+
+// DepthAt returns the cumulative quantity of one side of b at price or
+// better, i.e. of asks at or below price or of bids at or above it.
+// It returns zero if price is not a valid number.
+func (b *OrderBook) DepthAt(side string, price Number) Number {
+	buy, err := bookSide(side)
+	p, ok := price.Rat()
+	if err != nil || !ok {
+		return "0"
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	levels := b.asks
+	if buy {
+		levels = b.bids
+	}
+	sum := new(big.Rat)
+	for _, l := range levels {
+		c := l.price.Cmp(p)
+		if buy && c < 0 || !buy && c > 0 {
+			break
+		}
+		sum.Add(sum, l.quantity)
+	}
+	return formatExact(sum)
+}
+
+// Top returns copies of up to n best levels of each side, best first.
+// If n is not positive, all levels are returned.
+func (b *OrderBook) Top(n int) (ask, bid []*MarketOrder) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return topLevels(b.asks, n), topLevels(b.bids, n)
+}
+
+func topLevels(levels []bookLevel, n int) []*MarketOrder {
+	if n <= 0 || n > len(levels) {
+		n = len(levels)
+	}
+	orders := make([]*MarketOrder, n)
+	for i := range orders {
+		o := *levels[i].order
+		orders[i] = &o
+	}
+	return orders
+}
+
+func bookSide(side string) (buy bool, _ error) {
+	switch side {
+	case OrderSideBuy:
+		return true, nil
+	case OrderSideSell:
+		return false, nil
+	default:
+		return false, &Error{Message: fmt.Sprintf("invalid order book side %q", side)}
+	}
+}
+
+func newBookLevel(o *MarketOrder) (bookLevel, error) {
+	p, ok := o.Price.Rat()
+	if !ok || p.Sign() <= 0 {
+		return bookLevel{}, invalidNumberError(string(o.Price))
+	}
+	q, ok := o.Quantity.Rat()
+	if !ok || q.Sign() < 0 {
+		return bookLevel{}, invalidNumberError(string(o.Quantity))
+	}
+	c := *o
+	if c.Sum.IsUndefined() {
+		c.Sum = formatExact(new(big.Rat).Mul(q, p))
+	}
+	return bookLevel{price: p, quantity: q, order: &c}, nil
+}
+
+func newBookSide(orders []*MarketOrder, buy bool) ([]bookLevel, error) {
+	var levels []bookLevel
+	for _, o := range orders {
+		l, err := newBookLevel(o)
+		if err != nil {
+			return nil, err
+		}
+		levels = updateBookSide(levels, l, buy)
+	}
+	return levels, nil
+}
+
+// updateBookSide inserts, replaces or, if its quantity is zero, removes l.
+func updateBookSide(levels []bookLevel, l bookLevel, buy bool) []bookLevel {
+	i := sort.Search(len(levels), func(i int) bool {
+		c := levels[i].price.Cmp(l.price)
+		if buy {
+			return c <= 0
+		}
+		return c >= 0
+	})
+	found := i < len(levels) && levels[i].price.Cmp(l.price) == 0
+	switch {
+	case l.quantity.Sign() == 0:
+		if found {
+			levels = append(levels[:i], levels[i+1:]...)
+		}
+	case found:
+		levels[i] = l
+	default:
+		levels = append(levels, bookLevel{})
+		copy(levels[i+1:], levels[i:])
+		levels[i] = l
+	}
+	return levels
+}
+
+func crossed(asks, bids []bookLevel) bool {
+	return len(asks) > 0 && len(bids) > 0 && bids[0].price.Cmp(asks[0].price) >= 0
+}
+
+func crossedError(symbol string) error {
+	return &Error{
+		Message: ErrCrossedBook.Message + " for " + symbol,
+		kind:    ErrCrossedBook,
+	}
+}
@@ -0,0 +1,250 @@
+package wallex_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/wallextest"
+)
+
+// levels returns market orders from pairs of prices and quantities.
+func levels(pq ...wallex.Number) []*wallex.MarketOrder {
+	var orders []*wallex.MarketOrder
+	for i := 0; i < len(pq); i += 2 {
+		orders = append(orders, &wallex.MarketOrder{Price: pq[i], Quantity: pq[i+1]})
+	}
+	return orders
+}
+
+// sides returns the levels of b as "quantity@price", best first.
+func sides(b *wallex.OrderBook) (ask, bid []string) {
+	a, d := b.Top(0)
+	for _, o := range a {
+		ask = append(ask, string(o.Quantity)+"@"+string(o.Price))
+	}
+	for _, o := range d {
+		bid = append(bid, string(o.Quantity)+"@"+string(o.Price))
+	}
+	return ask, bid
+}
+
+func checkSides(t *testing.T, b *wallex.OrderBook, ask, bid []string) {
+	t.Helper()
+	gotAsk, gotBid := sides(b)
+	if !reflect.DeepEqual(gotAsk, ask) {
+		t.Errorf("asks = %v, want %v", gotAsk, ask)
+	}
+	if !reflect.DeepEqual(gotBid, bid) {
+		t.Errorf("bids = %v, want %v", gotBid, bid)
+	}
+}
+
+func TestOrderBookSnapshot(t *testing.T) {
+	srv := wallextest.NewServer()
+	defer srv.Close()
+	srv.SetMarketOrders("BTCTMN",
+		levels("102", "1", "101", "2", "103", "0", "104", "0.5"),
+		levels("99", "3", "100", "1.5", "98", "4"),
+	)
+
+	b, err := srv.Client(wallex.ClientOptions{}).OrderBook("BTCTMN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Symbol() != "BTCTMN" || b.UpdatedAt().IsZero() {
+		t.Errorf("Symbol = %q, UpdatedAt = %v", b.Symbol(), b.UpdatedAt())
+	}
+	// Levels are sorted best first and empty ones are dropped.
+	checkSides(t, b, []string{"2@101", "1@102", "0.5@104"}, []string{"1.5@100", "3@99", "4@98"})
+
+	if o, ok := b.BestAsk(); !ok || o.Price != "101" || o.Sum != "202" {
+		t.Errorf("BestAsk = %+v, %v, want 2@101 with a sum of 202", o, ok)
+	}
+	if o, ok := b.BestBid(); !ok || o.Price != "100" || o.Sum != "150" {
+		t.Errorf("BestBid = %+v, %v, want 1.5@100 with a sum of 150", o, ok)
+	}
+	ask, bid := b.Top(2)
+	if len(ask) != 2 || len(bid) != 2 {
+		t.Errorf("Top(2) returned %d asks and %d bids", len(ask), len(bid))
+	}
+	// Top returns copies.
+	ask[0].Price = "1"
+	if o, _ := b.BestAsk(); o.Price != "101" {
+		t.Errorf("changing a level returned by Top changed the book to %v", o.Price)
+	}
+}
+
+func TestOrderBookUpdate(t *testing.T) {
+	b, err := wallex.NewOrderBook("BTCTMN", levels("101", "1", "102", "2"), levels("100", "1", "99", "2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		side     string
+		levels   []*wallex.MarketOrder
+		ask, bid []string
+	}{
+		// Insert at the top, in the middle and at the bottom.
+		{wallex.OrderSideSell, levels("100.5", "3", "101.5", "1", "110", "9"),
+			[]string{"3@100.5", "1@101", "1@101.5", "2@102", "9@110"}, []string{"1@100", "2@99"}},
+		// Replace a level.
+		{wallex.OrderSideBuy, levels("99", "5"),
+			[]string{"3@100.5", "1@101", "1@101.5", "2@102", "9@110"}, []string{"1@100", "5@99"}},
+		// Remove levels by zero quantity, including one that does not exist.
+		{wallex.OrderSideSell, levels("100.5", "0", "110", "0", "105", "0"),
+			[]string{"1@101", "1@101.5", "2@102"}, []string{"1@100", "5@99"}},
+		{wallex.OrderSideBuy, levels("100", "0", "99", "0"),
+			[]string{"1@101", "1@101.5", "2@102"}, nil},
+	}
+	for i, step := range steps {
+		if err := b.Update(step.side, step.levels...); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		checkSides(t, b, step.ask, step.bid)
+	}
+	if _, ok := b.BestBid(); ok {
+		t.Error("BestBid of an empty side reports a level")
+	}
+
+	// Replace swaps a whole side, as depth events do.
+	if err := b.Replace(wallex.OrderSideBuy, levels("98", "1", "97", "0", "99", "2")); err != nil {
+		t.Fatal(err)
+	}
+	checkSides(t, b, []string{"1@101", "1@101.5", "2@102"}, []string{"2@99", "1@98"})
+
+	// Invalid updates are rejected and leave the book unchanged.
+	for _, tt := range []struct {
+		side   string
+		levels []*wallex.MarketOrder
+	}{
+		{"BOTH", levels("100", "1")},
+		{wallex.OrderSideBuy, levels("100", "1", "abc", "1")},
+		{wallex.OrderSideBuy, levels("0", "1")},
+		{wallex.OrderSideBuy, levels("100", "-1")},
+	} {
+		if err := b.Update(tt.side, tt.levels...); err == nil {
+			t.Errorf("Update(%q, %v) succeeded", tt.side, tt.levels)
+		}
+	}
+	checkSides(t, b, []string{"1@101", "1@101.5", "2@102"}, []string{"2@99", "1@98"})
+}
+
+func TestOrderBookCrossed(t *testing.T) {
+	if _, err := wallex.NewOrderBook("BTCTMN", levels("100", "1"), levels("100", "1")); !errors.Is(err, wallex.ErrCrossedBook) {
+		t.Errorf("NewOrderBook of a crossed snapshot = %v, want ErrCrossedBook", err)
+	}
+
+	srv := wallextest.NewServer()
+	defer srv.Close()
+	srv.SetMarketOrders("BTCTMN", levels("101", "1"), levels("100", "1"))
+	b, err := srv.Client(wallex.ClientOptions{}).OrderBook("BTCTMN")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A crossed snapshot does not replace the book.
+	if err := b.Reset(levels("99", "1"), levels("100", "1")); !errors.Is(err, wallex.ErrCrossedBook) {
+		t.Errorf("Reset to a crossed snapshot = %v, want ErrCrossedBook", err)
+	}
+	checkSides(t, b, []string{"1@101"}, []string{"1@100"})
+
+	// A crossing update is applied but reported.
+	if err := b.Update(wallex.OrderSideBuy, levels("101", "2")...); !errors.Is(err, wallex.ErrCrossedBook) {
+		t.Errorf("crossing Update = %v, want ErrCrossedBook", err)
+	}
+	if !b.Crossed() {
+		t.Error("Crossed = false after a crossing update")
+	}
+	if err := b.Update(wallex.OrderSideBuy, levels("101", "0")...); err != nil {
+		t.Errorf("uncrossing Update = %v", err)
+	}
+	if b.Crossed() {
+		t.Error("Crossed = true after the crossing level was removed")
+	}
+
+	// Sync resyncs from a snapshot when an event crosses the book.
+	srv.SetMarketOrders("BTCTMN", levels("106", "1"), levels("105", "1"))
+	ev := &wallex.DepthEvent{Symbol: "BTCTMN", Side: wallex.OrderSideBuy, Orders: levels("105", "1")}
+	if err := b.Sync(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	checkSides(t, b, []string{"1@106"}, []string{"1@105"})
+
+	// Events of other markets are ignored.
+	ev = &wallex.DepthEvent{Symbol: "ETHTMN", Side: wallex.OrderSideSell, Orders: levels("1", "1")}
+	if err := b.ApplyEvent(ev); err != nil {
+		t.Fatal(err)
+	}
+	checkSides(t, b, []string{"1@106"}, []string{"1@105"})
+
+	// A book without a client cannot resync.
+	b, err = wallex.NewOrderBook("BTCTMN", levels("101", "1"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Resync(context.Background()); err == nil {
+		t.Error("Resync without a client succeeded")
+	}
+}
+
+func TestOrderBookSpreadMid(t *testing.T) {
+	tests := []struct {
+		ask, bid    []*wallex.MarketOrder
+		spread, mid wallex.Number
+		ok          bool
+	}{
+		{levels("101", "1"), levels("100", "1"), "1", "100.5", true},
+		{levels("0.3", "1", "0.4", "1"), levels("0.1", "1"), "0.2", "0.2", true},
+		{levels("2", "1"), levels("1.999999999999999999", "1"), "0.000000000000000001", "1.9999999999999999995", true},
+		{levels("101", "1"), nil, "", "", false},
+		{nil, levels("100", "1"), "", "", false},
+	}
+	for _, tt := range tests {
+		b, err := wallex.NewOrderBook("BTCTMN", tt.ask, tt.bid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spread, ok := b.Spread(); spread != tt.spread || ok != tt.ok {
+			t.Errorf("Spread of %v/%v = %q, %v, want %q, %v", tt.ask, tt.bid, spread, ok, tt.spread, tt.ok)
+		}
+		if mid, ok := b.Mid(); mid != tt.mid || ok != tt.ok {
+			t.Errorf("Mid of %v/%v = %q, %v, want %q, %v", tt.ask, tt.bid, mid, ok, tt.mid, tt.ok)
+		}
+	}
+}
+
+func TestOrderBookDepthAt(t *testing.T) {
+	b, err := wallex.NewOrderBook("BTCTMN",
+		levels("101", "1", "102", "0.5", "104", "2"),
+		levels("100", "0.1", "99", "0.2", "97", "3"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		side  string
+		price wallex.Number
+		want  wallex.Number
+	}{
+		{wallex.OrderSideSell, "100", "0"},
+		{wallex.OrderSideSell, "101", "1"},
+		{wallex.OrderSideSell, "103", "1.5"},
+		{wallex.OrderSideSell, "104", "3.5"},
+		{wallex.OrderSideSell, "1000", "3.5"},
+		{wallex.OrderSideBuy, "101", "0"},
+		{wallex.OrderSideBuy, "100", "0.1"},
+		{wallex.OrderSideBuy, "98", "0.3"},
+		{wallex.OrderSideBuy, "1", "3.3"},
+		{wallex.OrderSideBuy, "abc", "0"},
+		{"BOTH", "100", "0"},
+	}
+	for _, tt := range tests {
+		if got := b.DepthAt(tt.side, tt.price); got != tt.want {
+			t.Errorf("DepthAt(%s, %s) = %s, want %s", tt.side, tt.price, got, tt.want)
+		}
+	}
+}
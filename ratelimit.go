@@ -0,0 +1,243 @@
+package wallex
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateGroup is a group of endpoints that share a rate limit budget.
+type RateGroup int
+
+// List of rate limit groups.
+const (
+	RateGroupPublic  RateGroup = iota // Public market data.
+	RateGroupAccount                  // Private account reads.
+	RateGroupOrder                    // Order placement and cancellation.
+)
+
+var rateGroupNames = [...]string{"public", "account", "order"}
+
+func (g RateGroup) String() string {
+	if g < 0 || int(g) >= len(rateGroupNames) {
+		return "unknown"
+	}
+	return rateGroupNames[g]
+}
+
+// Rate is a token bucket budget.
+// A zero Rate does not limit requests.
+type Rate struct {
+
+	// Limit is the number of requests allowed per second.
+	Limit float64
+
+	// Burst is the maximum number of requests sent at once.
+	// If zero, it defaults to 1.
+	Burst int
+}
+
+// RateLimits configures the budgets of a RateLimiter.
+type RateLimits struct {
+	Public  Rate
+	Account Rate
+	Order   Rate
+
+	// FailFast makes requests fail with ErrRateLimited instead of
+	// blocking until a slot is free.
+	FailFast bool
+}
+
+// RateUsage is a snapshot of a rate limit group's state.
+type RateUsage struct {
+	Limit     float64
+	Burst     int
+	Available float64       // Tokens currently available, negative if reserved ahead.
+	Waiting   int           // Requests currently waiting for a slot.
+	Allowed   uint64        // Requests allowed so far.
+	Rejected  uint64        // Requests rejected so far in fail-fast mode.
+	Waited    time.Duration // Total time requests spent waiting.
+}
+
+// RateLimiter throttles requests per endpoint group. It is safe for
+// concurrent use and may be shared between clients using the same API key.
+// A nil RateLimiter allows every request and reports no usage.
+type RateLimiter struct {
+	buckets  [3]*bucket
+	failFast bool
+}
+
+// NewRateLimiter instantiates a new RateLimiter.
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{
+		buckets: [3]*bucket{
+			newBucket(limits.Public),
+			newBucket(limits.Account),
+			newBucket(limits.Order),
+		},
+		failFast: limits.FailFast,
+	}
+}
+
+// Wait blocks until a request in group g is allowed or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, g RateGroup) error {
+	_, err := l.wait(ctx, g)
+	return err
+}
+
+// Allow reports whether a request in group g is allowed now,
+// consuming a slot if so.
+func (l *RateLimiter) Allow(g RateGroup) bool {
+	b := l.bucket(g)
+	return b == nil || b.allow(time.Now())
+}
+
+// Usage returns the current state of all groups, or nil if l is nil.
+func (l *RateLimiter) Usage() map[RateGroup]RateUsage {
+	if l == nil {
+		return nil
+	}
+	usage := make(map[RateGroup]RateUsage, len(l.buckets))
+	for g, b := range l.buckets {
+		usage[RateGroup(g)] = b.usage(time.Now())
+	}
+	return usage
+}
+
+// wait acquires a slot in group g according to the limiter's mode and
+// returns how long it waited for it.
+func (l *RateLimiter) wait(ctx context.Context, g RateGroup) (time.Duration, error) {
+	b := l.bucket(g)
+	if b == nil {
+		return 0, nil
+	}
+	if l.failFast {
+		if !b.allow(time.Now()) {
+			return 0, ErrRateLimited
+		}
+		return 0, nil
+	}
+	return b.wait(ctx)
+}
+
+func (l *RateLimiter) bucket(g RateGroup) *bucket {
+	if l == nil || g < 0 || int(g) >= len(l.buckets) {
+		return nil
+	}
+	return l.buckets[g]
+}
+
+// bucket is a token bucket.
+type bucket struct {
+	mu       sync.Mutex
+	rate     Rate
+	tokens   float64
+	last     time.Time
+	waiting  int
+	allowed  uint64
+	rejected uint64
+	waited   time.Duration
+}
+
+func newBucket(r Rate) *bucket {
+	if r.Burst <= 0 {
+		r.Burst = 1
+	}
+	return &bucket{
+		rate:   r,
+		tokens: float64(r.Burst),
+		last:   time.Now(),
+	}
+}
+
+// advance refills the tokens up to now. b.mu must be held.
+func (b *bucket) advance(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate.Limit
+		if burst := float64(b.rate.Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+	}
+}
+
+func (b *bucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate.Limit <= 0 {
+		b.allowed++
+		return true
+	}
+	b.advance(now)
+	if b.tokens < 1 {
+		b.rejected++
+		return false
+	}
+	b.tokens--
+	b.allowed++
+	return true
+}
+
+func (b *bucket) wait(ctx context.Context) (time.Duration, error) {
+	b.mu.Lock()
+	if b.rate.Limit <= 0 {
+		b.allowed++
+		b.mu.Unlock()
+		return 0, nil
+	}
+	b.advance(time.Now())
+	b.tokens--
+	if b.tokens >= 0 {
+		b.allowed++
+		b.mu.Unlock()
+		return 0, nil
+	}
+	d := time.Duration(-b.tokens / b.rate.Limit * float64(time.Second))
+	b.waiting++
+	b.mu.Unlock()
+
+	err := sleep(ctx, d)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.waiting--
+	if err != nil {
+		// Give back the reserved token.
+		b.tokens++
+		return 0, err
+	}
+	b.allowed++
+	b.waited += d
+	return d, nil
+}
+
+func (b *bucket) usage(now time.Time) RateUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(now)
+	return RateUsage{
+		Limit:     b.rate.Limit,
+		Burst:     b.rate.Burst,
+		Available: b.tokens,
+		Waiting:   b.waiting,
+		Allowed:   b.allowed,
+		Rejected:  b.rejected,
+		Waited:    b.waited,
+	}
+}
+
+// rateGroup returns the rate limit group of r.
+func (r *request) rateGroup() RateGroup {
+	switch {
+	case !r.private:
+		return RateGroupPublic
+	case r.method != http.MethodGet && strings.HasPrefix(r.path, "/v1/account/orders"):
+		return RateGroupOrder
+	default:
+		return RateGroupAccount
+	}
+}
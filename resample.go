@@ -0,0 +1,120 @@
+package wallex
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wallexchange/wallex-go/jalali"
+)
+
+// ResampleOptions customizes the alignment of resampled candles.
+type ResampleOptions struct {
+
+	// Location is where daily and weekly candles begin at midnight.
+	// Intraday candles that evenly divide a day begin at midnight too.
+	// If nil, it defaults to UTC.
+	Location *time.Location
+
+	// WeekStart is the first day of weekly candles.
+	WeekStart time.Weekday
+}
+
+// TehranAlignment returns options that align daily candles to midnight in
+// Tehran and weekly candles to Saturday, as the Iranian week begins.
+func TehranAlignment() ResampleOptions {
+	return ResampleOptions{Location: jalali.Tehran(), WeekStart: time.Saturday}
+}
+
+// Resample aggregates candles into candles of resolution r: the first open,
+// the highest high, the lowest low, the last close and the total volume of
+// each period. candles should be of a finer resolution that divides r; they
+// need not be sorted. Periods without candles are omitted and a period at
+// either end of the input may be partial.
+func Resample(candles []*Candle, r Resolution, opt ResampleOptions) ([]*Candle, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	sorted := append([]*Candle(nil), candles...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	var result []*Candle
+	var cur *Candle
+	for _, c := range sorted {
+		start := opt.align(c.Timestamp, r)
+		if cur == nil || !cur.Timestamp.Equal(start) {
+			cur = &Candle{
+				Timestamp: start,
+				Open:      c.Open,
+				High:      c.High,
+				Low:       c.Low,
+				Close:     c.Close,
+				Volume:    c.Volume,
+			}
+			result = append(result, cur)
+			continue
+		}
+		if c.High.Cmp(cur.High) > 0 {
+			cur.High = c.High
+		}
+		if !c.Low.IsUndefined() && (cur.Low.IsUndefined() || c.Low.Cmp(cur.Low) < 0) {
+			cur.Low = c.Low
+		}
+		cur.Close = c.Close
+		switch {
+		case cur.Volume.IsUndefined():
+			cur.Volume = c.Volume
+		case !c.Volume.IsUndefined():
+			cur.Volume = cur.Volume.Add(c.Volume)
+		}
+	}
+	return result, nil
+}
+
+// align returns the beginning of the period of resolution r containing t.
+func (opt ResampleOptions) align(t time.Time, r Resolution) time.Time {
+	loc := opt.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	n, unit := r.split()
+	d := time.Duration(n) * unit
+	const day = 24 * time.Hour
+
+	switch {
+	case d == 0:
+		return t
+	case unit < day:
+		if day%d != 0 {
+			return t.Truncate(d)
+		}
+		y, m, dd := t.Date()
+		midnight := time.Date(y, m, dd, 0, 0, 0, 0, loc)
+		return midnight.Add(t.Sub(midnight) / d * d)
+	}
+
+	// Count civil days since 1970-01-01, a Thursday, so that periods of
+	// several days or weeks line up regardless of the input.
+	y, m, dd := t.Date()
+	days := int(time.Date(y, m, dd, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+	if unit == day {
+		days -= floorMod(days, n)
+	} else {
+		days -= floorMod(days+int(time.Thursday)-int(opt.WeekStart), 7)
+		anchor := floorMod(int(opt.WeekStart)-int(time.Thursday), 7)
+		days -= floorMod((days-anchor)/7, n) * 7
+	}
+	y, m, dd = time.Unix(int64(days)*86400, 0).UTC().Date()
+	return time.Date(y, m, dd, 0, 0, 0, 0, loc)
+}
+
+func floorMod(a, b int) int {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
@@ -0,0 +1,168 @@
+package wallex_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+	_ "time/tzdata" // Asia/Tehran without a system time zone database.
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/wallextest"
+)
+
+// series returns n candles every step from start. Candle i opens at i,
+// closes at i+1, ranges from i to i+10 and has a volume of 1.
+func series(start string, step time.Duration, n int) []*wallex.Candle {
+	t, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		panic(err)
+	}
+	candles := make([]*wallex.Candle, n)
+	for i := range candles {
+		candles[i] = &wallex.Candle{
+			Timestamp: t.Add(time.Duration(i) * step),
+			Open:      wallex.Number(strconv.Itoa(i)),
+			High:      wallex.Number(strconv.Itoa(i + 10)),
+			Low:       wallex.Number(strconv.Itoa(i)),
+			Close:     wallex.Number(strconv.Itoa(i + 1)),
+			Volume:    "1",
+		}
+	}
+	return candles
+}
+
+// bar is a resampled candle made of candles first to last of a series.
+type bar struct {
+	start       string
+	first, last int
+}
+
+func checkBars(t *testing.T, got []*wallex.Candle, want []bar) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d bars, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		ts, err := time.Parse(time.RFC3339, w.start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := got[i]
+		if !b.Timestamp.Equal(ts) ||
+			b.Open != wallex.Number(strconv.Itoa(w.first)) ||
+			b.High != wallex.Number(strconv.Itoa(w.last+10)) ||
+			b.Low != wallex.Number(strconv.Itoa(w.first)) ||
+			b.Close != wallex.Number(strconv.Itoa(w.last+1)) ||
+			b.Volume != wallex.Number(strconv.Itoa(w.last-w.first+1)) {
+			t.Errorf("bar %d = %v %s/%s/%s/%s/%s, want %s of candles %d to %d",
+				i, b.Timestamp, b.Open, b.High, b.Low, b.Close, b.Volume, w.start, w.first, w.last)
+		}
+	}
+}
+
+func TestResampleTehranDaily(t *testing.T) {
+	// Hourly candles from 21:30 on 1 Farvardin 1403 in Tehran, which is
+	// 3.5 hours ahead of UTC.
+	candles := series("2024-03-20T18:00:00Z", time.Hour, 29)
+
+	got, err := wallex.Resample(candles, wallex.Day, wallex.TehranAlignment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBars(t, got, []bar{
+		{"2024-03-20T00:00:00+03:30", 0, 2},
+		{"2024-03-21T00:00:00+03:30", 3, 26},
+		{"2024-03-22T00:00:00+03:30", 27, 28},
+	})
+	if loc := got[0].Timestamp.Location(); loc.String() != "Asia/Tehran" {
+		t.Errorf("bars are in %v, want Asia/Tehran", loc)
+	}
+
+	// The same candles aligned to UTC.
+	got, err = wallex.Resample(candles, wallex.Day, wallex.ResampleOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBars(t, got, []bar{
+		{"2024-03-20T00:00:00Z", 0, 5},
+		{"2024-03-21T00:00:00Z", 6, 28},
+	})
+
+	// Intraday bars that divide a day begin at midnight in Tehran too.
+	got, err = wallex.Resample(candles[:8], wallex.FourHour, wallex.TehranAlignment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBars(t, got, []bar{
+		{"2024-03-20T20:00:00+03:30", 0, 2},
+		{"2024-03-21T00:00:00+03:30", 3, 6},
+		{"2024-03-21T04:00:00+03:30", 7, 7},
+	})
+}
+
+func TestResampleTehranWeekly(t *testing.T) {
+	// Daily candles from Monday 18 March 2024 to Sunday 31 March 2024,
+	// starting at midnight in Tehran.
+	candles := series("2024-03-17T20:30:00Z", 24*time.Hour, 14)
+
+	got, err := wallex.Resample(candles, wallex.Week, wallex.TehranAlignment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Iranian weeks begin on Saturday.
+	checkBars(t, got, []bar{
+		{"2024-03-16T00:00:00+03:30", 0, 4},
+		{"2024-03-23T00:00:00+03:30", 5, 11},
+		{"2024-03-30T00:00:00+03:30", 12, 13},
+	})
+
+	got, err = wallex.Resample(candles, "2W", wallex.TehranAlignment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Three weeks of candles span two periods of two weeks.
+	if len(got) != 2 {
+		t.Fatalf("got %d 2W bars, want 2", len(got))
+	}
+	if d := got[1].Timestamp.Sub(got[0].Timestamp); d != 14*24*time.Hour {
+		t.Errorf("2W bars are %v apart", d)
+	}
+	for _, b := range got {
+		if b.Timestamp.Weekday() != time.Saturday {
+			t.Errorf("2W bar begins on %v, want Saturday", b.Timestamp.Weekday())
+		}
+	}
+
+	// Resampling the shuffled input gives the same bars.
+	shuffled := append([]*wallex.Candle{}, candles[7:]...)
+	shuffled = append(shuffled, candles[:7]...)
+	got, err = wallex.Resample(shuffled, wallex.Week, wallex.TehranAlignment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBars(t, got, []bar{
+		{"2024-03-16T00:00:00+03:30", 0, 4},
+		{"2024-03-23T00:00:00+03:30", 5, 11},
+		{"2024-03-30T00:00:00+03:30", 12, 13},
+	})
+}
+
+func TestCandlesResampled(t *testing.T) {
+	srv := wallextest.NewServer()
+	defer srv.Close()
+	candles := series("2024-03-20T00:00:00Z", time.Hour, 10)
+	srv.SetCandles("BTCTMN", wallex.Hour, candles...)
+
+	// Four-hour candles are not served by the API but resampled from
+	// hourly ones, aligned to UTC.
+	from := candles[1].Timestamp
+	got, err := srv.Client(wallex.ClientOptions{}).Candles("BTCTMN", wallex.FourHour, from, candles[9].Timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBars(t, got, []bar{
+		{"2024-03-20T00:00:00Z", 0, 3},
+		{"2024-03-20T04:00:00Z", 4, 7},
+		{"2024-03-20T08:00:00Z", 8, 9},
+	})
+}
@@ -0,0 +1,136 @@
+package wallex
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried.
+//
+// Connection failures and 429, 502, 503 and 504 responses are retried.
+// GET requests are always retried, while PlaceOrder is only retried when
+// OrderParams.ClientID is set, so that a retry never places a duplicate order.
+type RetryPolicy struct {
+
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// If zero, it defaults to 4.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry.
+	// If zero, it defaults to 200ms.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the exponentially growing delay between retries.
+	// If zero, it defaults to 5s.
+	MaxBackoff time.Duration
+
+	// Jitter is the fraction in [0, 1] of each delay that is randomized.
+	// If zero, delays are not randomized.
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns a retry policy with reasonable defaults.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 4,
+		MinBackoff:  200 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+		Jitter:      0.5,
+	}
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil {
+		return 1
+	}
+	if p.MaxAttempts <= 0 {
+		return 4
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the delay after the given failed attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	lo, hi := p.MinBackoff, p.MaxBackoff
+	if lo <= 0 {
+		lo = 200 * time.Millisecond
+	}
+	if hi <= 0 {
+		hi = 5 * time.Second
+	}
+
+	d := lo
+	for i := 1; i < attempt && d < hi; i++ {
+		d *= 2
+	}
+	if d > hi {
+		d = hi
+	}
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d -= time.Duration(j * randFloat64() * float64(d))
+	}
+	return d
+}
+
+var (
+	randMu sync.Mutex
+	rnd    = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func randFloat64() float64 {
+	randMu.Lock()
+	defer randMu.Unlock()
+	return rnd.Float64()
+}
+
+// retryableStatus reports whether a response status is likely transient.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter returns the delay requested by Retry-After header of a 429 or
+// 503 response, or zero if there is none.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,452 @@
+package wallex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wallexchange/wallex-go/internal/websocket"
+)
+
+// List of stream channel suffixes. A channel name is the market symbol
+// followed by one of them, e.g. "BTCTMN@trade".
+const (
+	ChannelBuyDepth  = "@buyDepth"
+	ChannelSellDepth = "@sellDepth"
+	ChannelTrade     = "@trade"
+	ChannelMarketCap = "@marketCap"
+)
+
+// engineIOVersion is the Engine.IO protocol version spoken by Stream,
+// that of socket.io 3 and later.
+const engineIOVersion = "4"
+
+// ErrStreamProtocol is reported through StreamOptions.OnError when the
+// server does not speak the protocol a Stream expects, e.g. an older
+// Engine.IO version. Reconnecting is unlikely to help.
+var ErrStreamProtocol = &Error{Message: "unsupported stream protocol"}
+
+// StreamEvent is an event delivered by a Stream.
+// It is one of *DepthEvent, *TradeEvent, *TickerEvent or *RawEvent.
+type StreamEvent interface {
+	// Channel returns the channel the event was published on.
+	Channel() string
+}
+
+// DepthEvent is a snapshot of one side of a market order book.
+type DepthEvent struct {
+	Symbol string
+	Side   string // OrderSideBuy for bids, OrderSideSell for asks.
+	Orders []*MarketOrder
+}
+
+// Channel implements StreamEvent.
+func (e *DepthEvent) Channel() string {
+	if e.Side == OrderSideBuy {
+		return e.Symbol + ChannelBuyDepth
+	}
+	return e.Symbol + ChannelSellDepth
+}
+
+// TradeEvent is a trade in a market.
+type TradeEvent struct {
+	Symbol string
+	Trade  *MarketTrade
+}
+
+// Channel implements StreamEvent.
+func (e *TradeEvent) Channel() string {
+	return e.Symbol + ChannelTrade
+}
+
+// TickerEvent is an update of the 24h statistics of a market.
+type TickerEvent struct {
+	Symbol string
+	Stats  *MarketStats
+}
+
+// Channel implements StreamEvent.
+func (e *TickerEvent) Channel() string {
+	return e.Symbol + ChannelMarketCap
+}
+
+// RawEvent is an event of a channel the client does not know how to decode.
+type RawEvent struct {
+	Name string
+	Data json.RawMessage
+}
+
+// Channel implements StreamEvent.
+func (e *RawEvent) Channel() string {
+	return e.Name
+}
+
+// StreamOptions customizes a Stream.
+type StreamOptions struct {
+
+	// Handler, if not nil, is called with every event instead of sending it
+	// to the Events channel. It is called from the reading goroutine, so
+	// a slow handler delays heartbeats and may cause reconnects.
+	Handler func(StreamEvent)
+
+	// Buffer is the capacity of the Events channel. If zero, it defaults
+	// to 256.
+	Buffer int
+
+	// MinReconnectDelay is the delay before the first reconnect attempt.
+	// It doubles with each failed attempt up to MaxReconnectDelay.
+	// They default to 500ms and 30s.
+	MinReconnectDelay time.Duration
+	MaxReconnectDelay time.Duration
+
+	// OnError, if not nil, is called with the error that ended a connection
+	// attempt, before reconnecting.
+	OnError func(error)
+}
+
+// Stream is a connection to the real-time market data feed of Wallex,
+// served over socket.io. It reconnects automatically and resubscribes
+// to its channels after every reconnect.
+type Stream struct {
+	url    string
+	dialer *websocket.Dialer
+	opt    StreamOptions
+	events chan StreamEvent
+
+	mu       sync.Mutex
+	channels []string
+	conn     *websocket.Conn
+}
+
+// Stream returns a new stream of c's streaming endpoint. Subscribe to
+// channels, then call Run to connect. The stream dials with the proxy, TLS
+// configuration and dialer of c's HTTP transport if it is an
+// *http.Transport, and with those of http.DefaultTransport otherwise.
+func (c *Client) Stream(opt StreamOptions) *Stream {
+	if opt.Buffer <= 0 {
+		opt.Buffer = 256
+	}
+	if opt.MinReconnectDelay <= 0 {
+		opt.MinReconnectDelay = 500 * time.Millisecond
+	}
+	if opt.MaxReconnectDelay <= 0 {
+		opt.MaxReconnectDelay = 30 * time.Second
+	}
+	s := &Stream{
+		url:    c.streamURL + "/socket.io/?EIO=" + engineIOVersion + "&transport=websocket",
+		dialer: streamDialer(c.httpClient),
+		opt:    opt,
+	}
+	if opt.Handler == nil {
+		s.events = make(chan StreamEvent, opt.Buffer)
+	}
+	return s
+}
+
+// Events returns the channel events are delivered on. It is closed when Run
+// returns. It is nil if the stream has a Handler.
+func (s *Stream) Events() <-chan StreamEvent {
+	return s.events
+}
+
+// SubscribeDepth subscribes to both sides of the order book of symbol.
+func (s *Stream) SubscribeDepth(symbol string) error {
+	return s.Subscribe(symbol+ChannelBuyDepth, symbol+ChannelSellDepth)
+}
+
+// SubscribeTrades subscribes to the trades of symbol.
+func (s *Stream) SubscribeTrades(symbol string) error {
+	return s.Subscribe(symbol + ChannelTrade)
+}
+
+// SubscribeTicker subscribes to the 24h statistics of symbol.
+func (s *Stream) SubscribeTicker(symbol string) error {
+	return s.Subscribe(symbol + ChannelMarketCap)
+}
+
+// Subscribe subscribes to channels by name. Subscriptions are kept for the
+// lifetime of s and renewed on every reconnect. If s is connected, they are
+// sent right away and a failure to send them is returned.
+func (s *Stream) Subscribe(channels ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range channels {
+		if containsString(s.channels, ch) {
+			continue
+		}
+		s.channels = append(s.channels, ch)
+		if s.conn != nil {
+			if err := emit(s.conn, "subscribe", map[string]string{"channel": ch}); err != nil {
+				return wrapStreamError(err)
+			}
+		}
+	}
+	return nil
+}
+
+// Channels returns the subscribed channels.
+func (s *Stream) Channels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.channels...)
+}
+
+// Run connects to the feed and delivers events until ctx is done,
+// reconnecting on failures. It returns ctx.Err() and closes the Events
+// channel. Run must be called at most once.
+func (s *Stream) Run(ctx context.Context) error {
+	if s.events != nil {
+		defer close(s.events)
+	}
+
+	delay := s.opt.MinReconnectDelay
+	for {
+		connected, err := s.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if s.opt.OnError != nil {
+			s.opt.OnError(err)
+		}
+		if connected {
+			delay = s.opt.MinReconnectDelay
+		}
+		// Jitter the delay, so that many clients do not reconnect at once.
+		d := delay/2 + time.Duration(randFloat64()*float64(delay/2))
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+		if delay *= 2; delay > s.opt.MaxReconnectDelay {
+			delay = s.opt.MaxReconnectDelay
+		}
+	}
+}
+
+// session runs a single connection until it fails. It reports whether
+// the socket.io handshake succeeded.
+func (s *Stream) session(ctx context.Context) (connected bool, err error) {
+	conn, err := s.dialer.Dial(ctx, s.url, nil)
+	if err != nil {
+		var he *websocket.HandshakeError
+		if errors.As(err, &he) && isUnsupportedVersion(he) {
+			return false, &Error{
+				Message: "server does not support Engine.IO protocol version " + engineIOVersion,
+				kind:    ErrStreamProtocol,
+				Cause:   err,
+			}
+		}
+		return false, wrapStreamError(err)
+	}
+	defer conn.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	// Engine.IO opens with its parameters, then the client joins the
+	// default socket.io namespace.
+	var open struct {
+		SID          string `json:"sid"`
+		PingInterval int    `json:"pingInterval"`
+		PingTimeout  int    `json:"pingTimeout"`
+	}
+	packet, err := readPacket(conn, 30*time.Second)
+	if err != nil {
+		return false, wrapStreamError(err)
+	}
+	if !strings.HasPrefix(packet, "0") || json.Unmarshal([]byte(packet[1:]), &open) != nil ||
+		open.SID == "" || open.PingInterval <= 0 || open.PingTimeout <= 0 {
+		return false, &Error{
+			Message: fmt.Sprintf("unexpected Engine.IO open packet %q", packet),
+			kind:    ErrStreamProtocol,
+		}
+	}
+	// The server pings every interval and expects a pong within the timeout.
+	timeout := time.Duration(open.PingInterval+open.PingTimeout) * time.Millisecond
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("40")); err != nil {
+		return false, wrapStreamError(err)
+	}
+
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+
+	for {
+		packet, err := readPacket(conn, timeout)
+		if err != nil {
+			return connected, wrapStreamError(err)
+		}
+		switch {
+		case packet == "2":
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("3")); err != nil {
+				return connected, wrapStreamError(err)
+			}
+		case packet == "1":
+			return connected, wrapStreamError(errors.New("closed by server"))
+		case strings.HasPrefix(packet, "40"):
+			if connected {
+				continue
+			}
+			connected = true
+			if err := s.resubscribe(conn); err != nil {
+				return connected, err
+			}
+		case strings.HasPrefix(packet, "41"):
+			return connected, wrapStreamError(errors.New("disconnected by server"))
+		case strings.HasPrefix(packet, "44"):
+			return connected, wrapStreamError(fmt.Errorf("connect error: %s", packet[2:]))
+		case strings.HasPrefix(packet, "42"):
+			ev := decodeEvent(packet[2:])
+			if ev == nil {
+				continue
+			}
+			if s.opt.Handler != nil {
+				s.opt.Handler(ev)
+				continue
+			}
+			select {
+			case s.events <- ev:
+			case <-ctx.Done():
+				return connected, ctx.Err()
+			}
+		}
+	}
+}
+
+func (s *Stream) resubscribe(conn *websocket.Conn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.channels {
+		if err := emit(conn, "subscribe", map[string]string{"channel": ch}); err != nil {
+			return wrapStreamError(err)
+		}
+	}
+	s.conn = conn
+	return nil
+}
+
+func readPacket(conn *websocket.Conn, timeout time.Duration) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// emit sends a socket.io event with a single argument.
+func emit(conn *websocket.Conn, event string, arg interface{}) error {
+	data, err := json.Marshal([]interface{}{event, arg})
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, append([]byte("42"), data...))
+}
+
+// decodeEvent decodes the payload of a socket.io event packet. Market data
+// is published as ["Broadcaster", channel, data]; other events are ignored.
+func decodeEvent(payload string) StreamEvent {
+	// Skip the namespace and acknowledgement id, if any.
+	i := strings.IndexByte(payload, '[')
+	if i < 0 {
+		return nil
+	}
+	var args []json.RawMessage
+	if err := json.Unmarshal([]byte(payload[i:]), &args); err != nil || len(args) < 3 {
+		return nil
+	}
+	var name, channel string
+	if json.Unmarshal(args[0], &name) != nil || name != "Broadcaster" ||
+		json.Unmarshal(args[1], &channel) != nil {
+		return nil
+	}
+	data := args[2]
+
+	at := strings.LastIndexByte(channel, '@')
+	if at < 0 {
+		return &RawEvent{Name: channel, Data: data}
+	}
+	symbol := channel[:at]
+	switch channel[at:] {
+	case ChannelBuyDepth, ChannelSellDepth:
+		side := OrderSideSell
+		if channel[at:] == ChannelBuyDepth {
+			side = OrderSideBuy
+		}
+		var orders []*MarketOrder
+		if json.Unmarshal(data, &orders) != nil {
+			break
+		}
+		return &DepthEvent{Symbol: symbol, Side: side, Orders: orders}
+	case ChannelTrade:
+		var t MarketTrade
+		if json.Unmarshal(data, &t) != nil {
+			break
+		}
+		if t.Symbol == "" {
+			t.Symbol = symbol
+		}
+		return &TradeEvent{Symbol: symbol, Trade: &t}
+	case ChannelMarketCap:
+		var stats MarketStats
+		if json.Unmarshal(data, &stats) != nil {
+			break
+		}
+		return &TickerEvent{Symbol: symbol, Stats: &stats}
+	}
+	return &RawEvent{Name: channel, Data: data}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func wrapStreamError(err error) error {
+	return &Error{Message: "stream failed", Cause: err}
+}
+
+// streamDialer returns a dialer that connects like hc does.
+func streamDialer(hc *http.Client) *websocket.Dialer {
+	rt := hc.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	return &websocket.Dialer{
+		DialContext:     t.DialContext,
+		TLSClientConfig: t.TLSClientConfig,
+		Proxy:           t.Proxy,
+	}
+}
+
+// isUnsupportedVersion reports whether a rejected handshake is the
+// Engine.IO "Unsupported protocol version" error.
+func isUnsupportedVersion(he *websocket.HandshakeError) bool {
+	var body struct {
+		Code *int `json:"code"`
+	}
+	return he.StatusCode == http.StatusBadRequest &&
+		json.Unmarshal(he.Body, &body) == nil && body.Code != nil && *body.Code == 5
+}
@@ -0,0 +1,135 @@
+package wallex_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/wallextest"
+)
+
+var streamChannels = []string{"BTCTMN@buyDepth", "BTCTMN@sellDepth", "BTCTMN@trade", "BTCTMN@marketCap"}
+
+// publishAll publishes an event to every channel once a stream receives it.
+func publishAll(t *testing.T, srv *wallextest.Server, price string) {
+	t.Helper()
+	data := map[string]interface{}{
+		"BTCTMN@buyDepth":  []map[string]string{{"price": price, "quantity": "1", "sum": price}},
+		"BTCTMN@sellDepth": []map[string]string{{"price": price, "quantity": "2", "sum": price}},
+		"BTCTMN@trade":     map[string]interface{}{"price": price, "quantity": "0.5", "isBuyOrder": true},
+		"BTCTMN@marketCap": map[string]interface{}{"lastPrice": price, "direction": map[string]int{"BUY": 2, "SELL": 1}},
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for _, ch := range streamChannels {
+		for srv.Publish(ch, data[ch]) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("no stream subscribed to %s", ch)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+// receiveAll returns the next event of every channel, keyed by channel.
+func receiveAll(t *testing.T, events <-chan wallex.StreamEvent) map[string]wallex.StreamEvent {
+	t.Helper()
+	got := map[string]wallex.StreamEvent{}
+	timeout := time.After(5 * time.Second)
+	for len(got) < len(streamChannels) {
+		select {
+		case ev := <-events:
+			got[ev.Channel()] = ev
+		case <-timeout:
+			t.Fatalf("got events of %d channels, want %d", len(got), len(streamChannels))
+		}
+	}
+	return got
+}
+
+func checkEvents(t *testing.T, got map[string]wallex.StreamEvent, price wallex.Number) {
+	t.Helper()
+	if ev, ok := got["BTCTMN@buyDepth"].(*wallex.DepthEvent); !ok || ev.Side != wallex.OrderSideBuy ||
+		len(ev.Orders) != 1 || ev.Orders[0].Price != price {
+		t.Errorf("buy depth event = %+v", got["BTCTMN@buyDepth"])
+	}
+	if ev, ok := got["BTCTMN@sellDepth"].(*wallex.DepthEvent); !ok || ev.Side != wallex.OrderSideSell ||
+		len(ev.Orders) != 1 || ev.Orders[0].Quantity != "2" {
+		t.Errorf("sell depth event = %+v", got["BTCTMN@sellDepth"])
+	}
+	if ev, ok := got["BTCTMN@trade"].(*wallex.TradeEvent); !ok || ev.Symbol != "BTCTMN" ||
+		ev.Trade.Symbol != "BTCTMN" || ev.Trade.Price != price || !ev.Trade.IsBuyOrder {
+		t.Errorf("trade event = %+v", got["BTCTMN@trade"])
+	}
+	if ev, ok := got["BTCTMN@marketCap"].(*wallex.TickerEvent); !ok || ev.Stats.LastPrice != price ||
+		ev.Stats.Direction.Buy != 2 {
+		t.Errorf("ticker event = %+v", got["BTCTMN@marketCap"])
+	}
+}
+
+func TestStreamReconnects(t *testing.T) {
+	srv := wallextest.NewServer()
+	defer srv.Close()
+
+	errs := make(chan error, 16)
+	s := srv.Client(wallex.ClientOptions{}).Stream(wallex.StreamOptions{
+		MinReconnectDelay: 10 * time.Millisecond,
+		OnError:           func(err error) { errs <- err },
+	})
+	s.SubscribeDepth("BTCTMN")
+	s.SubscribeTrades("BTCTMN")
+	s.SubscribeTicker("BTCTMN")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	publishAll(t, srv, "1")
+	checkEvents(t, receiveAll(t, s.Events()), "1")
+
+	srv.DropStreams()
+	select {
+	case err := <-errs:
+		t.Logf("connection ended: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("dropped connection is not reported")
+	}
+
+	// The stream reconnects and subscribes to the same channels again.
+	publishAll(t, srv, "2")
+	checkEvents(t, receiveAll(t, s.Events()), "2")
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run = %v, want context.Canceled", err)
+	}
+	if _, ok := <-s.Events(); ok {
+		t.Error("Events is not closed")
+	}
+}
+
+func TestStreamUnsupportedVersion(t *testing.T) {
+	// An Engine.IO 3 server rejects version 4 clients.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":5,"message":"Unsupported protocol version"}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got error
+	c := wallex.New(wallex.ClientOptions{Environment: wallex.LocalEnvironment(srv.URL)})
+	s := c.Stream(wallex.StreamOptions{OnError: func(err error) {
+		got = err
+		cancel()
+	}})
+	s.Run(ctx)
+	if !errors.Is(got, wallex.ErrStreamProtocol) {
+		t.Errorf("OnError got %v, want ErrStreamProtocol", got)
+	}
+}
@@ -0,0 +1,424 @@
+package wallexio
+
+import (
+	"io"
+	"strconv"
+
+	wallex "github.com/wallexchange/wallex-go"
+)
+
+func formatBool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+func formatOptionalNumber(n *wallex.Number) string {
+	if n == nil {
+		return ""
+	}
+	return string(*n)
+}
+
+// -----------------------------------------------------------------------------
+// Candles
+// -----------------------------------------------------------------------------
+
+var candleSchema = []column{
+	{"timestamp", kindTime},
+	{"open", kindNumber},
+	{"high", kindNumber},
+	{"low", kindNumber},
+	{"close", kindNumber},
+	{"volume", kindNumber},
+}
+
+// CandleColumns are the columns of candle files, in order.
+var CandleColumns = columnNames(candleSchema)
+
+// CandleWriter writes candles.
+type CandleWriter struct {
+	w *writer
+}
+
+// NewCandleWriter returns a writer of candles to w.
+// The caller must call Flush when done.
+func NewCandleWriter(w io.Writer, format Format, opt Options) *CandleWriter {
+	return &CandleWriter{newWriter(w, candleSchema, format, opt)}
+}
+
+// Write writes candles.
+func (w *CandleWriter) Write(candles ...*wallex.Candle) error {
+	for _, c := range candles {
+		err := w.w.write([]string{
+			w.w.opt.formatTime(c.Timestamp),
+			string(c.Open),
+			string(c.High),
+			string(c.Low),
+			string(c.Close),
+			string(c.Volume),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *CandleWriter) Flush() error {
+	return w.w.flush()
+}
+
+// CandleReader reads candles.
+type CandleReader struct {
+	r *reader
+}
+
+// NewCandleReader returns a reader of candles from r.
+func NewCandleReader(r io.Reader, format Format, opt Options) *CandleReader {
+	return &CandleReader{newReader(r, candleSchema, format, opt)}
+}
+
+// Read reads the next candle. It returns io.EOF at the end of input.
+func (r *CandleReader) Read() (*wallex.Candle, error) {
+	fields, err := r.r.read()
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{r: r.r, fields: fields}
+	c := &wallex.Candle{
+		Timestamp: d.time(0),
+		Open:      d.number(1),
+		High:      d.number(2),
+		Low:       d.number(3),
+		Close:     d.number(4),
+		Volume:    d.number(5),
+	}
+	return c, d.err
+}
+
+// ReadAll reads the remaining candles.
+func (r *CandleReader) ReadAll() ([]*wallex.Candle, error) {
+	var candles []*wallex.Candle
+	for {
+		c, err := r.Read()
+		if err == io.EOF {
+			return candles, nil
+		}
+		if err != nil {
+			return candles, err
+		}
+		candles = append(candles, c)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Market trades
+// -----------------------------------------------------------------------------
+
+var marketTradeSchema = []column{
+	{"symbol", kindString},
+	{"timestamp", kindTime},
+	{"price", kindNumber},
+	{"quantity", kindNumber},
+	{"sum", kindNumber},
+	{"is_buy_order", kindBool},
+}
+
+// MarketTradeColumns are the columns of market trade files, in order.
+var MarketTradeColumns = columnNames(marketTradeSchema)
+
+// MarketTradeWriter writes market trades.
+type MarketTradeWriter struct {
+	w *writer
+}
+
+// NewMarketTradeWriter returns a writer of market trades to w.
+// The caller must call Flush when done.
+func NewMarketTradeWriter(w io.Writer, format Format, opt Options) *MarketTradeWriter {
+	return &MarketTradeWriter{newWriter(w, marketTradeSchema, format, opt)}
+}
+
+// Write writes trades.
+func (w *MarketTradeWriter) Write(trades ...*wallex.MarketTrade) error {
+	for _, t := range trades {
+		err := w.w.write([]string{
+			t.Symbol,
+			w.w.opt.formatTime(t.Timestamp),
+			string(t.Price),
+			string(t.Quantity),
+			string(t.Sum),
+			formatBool(t.IsBuyOrder),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *MarketTradeWriter) Flush() error {
+	return w.w.flush()
+}
+
+// MarketTradeReader reads market trades.
+type MarketTradeReader struct {
+	r *reader
+}
+
+// NewMarketTradeReader returns a reader of market trades from r.
+func NewMarketTradeReader(r io.Reader, format Format, opt Options) *MarketTradeReader {
+	return &MarketTradeReader{newReader(r, marketTradeSchema, format, opt)}
+}
+
+// Read reads the next trade. It returns io.EOF at the end of input.
+func (r *MarketTradeReader) Read() (*wallex.MarketTrade, error) {
+	fields, err := r.r.read()
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{r: r.r, fields: fields}
+	t := &wallex.MarketTrade{
+		Symbol:     fields[0],
+		Timestamp:  d.time(1),
+		Price:      d.number(2),
+		Quantity:   d.number(3),
+		Sum:        d.number(4),
+		IsBuyOrder: d.bool(5),
+	}
+	return t, d.err
+}
+
+// ReadAll reads the remaining trades.
+func (r *MarketTradeReader) ReadAll() ([]*wallex.MarketTrade, error) {
+	var trades []*wallex.MarketTrade
+	for {
+		t, err := r.Read()
+		if err == io.EOF {
+			return trades, nil
+		}
+		if err != nil {
+			return trades, err
+		}
+		trades = append(trades, t)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Account trades
+// -----------------------------------------------------------------------------
+
+var tradeSchema = []column{
+	{"symbol", kindString},
+	{"timestamp", kindTime},
+	{"price", kindNumber},
+	{"quantity", kindNumber},
+	{"sum", kindNumber},
+	{"fee", kindNumber},
+	{"fee_coefficient", kindNumber},
+	{"fee_asset", kindString},
+	{"is_buyer", kindBool},
+}
+
+// TradeColumns are the columns of account trade files, in order.
+var TradeColumns = columnNames(tradeSchema)
+
+// TradeWriter writes account trades.
+type TradeWriter struct {
+	w *writer
+}
+
+// NewTradeWriter returns a writer of account trades to w.
+// The caller must call Flush when done.
+func NewTradeWriter(w io.Writer, format Format, opt Options) *TradeWriter {
+	return &TradeWriter{newWriter(w, tradeSchema, format, opt)}
+}
+
+// Write writes trades.
+func (w *TradeWriter) Write(trades ...*wallex.Trade) error {
+	for _, t := range trades {
+		err := w.w.write([]string{
+			t.Symbol,
+			w.w.opt.formatTime(t.Timestamp),
+			string(t.Price),
+			string(t.Quantity),
+			string(t.Sum),
+			string(t.Fee),
+			string(t.FeeCoefficient),
+			t.FeeAsset,
+			formatBool(t.IsBuyer),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *TradeWriter) Flush() error {
+	return w.w.flush()
+}
+
+// TradeReader reads account trades.
+type TradeReader struct {
+	r *reader
+}
+
+// NewTradeReader returns a reader of account trades from r.
+func NewTradeReader(r io.Reader, format Format, opt Options) *TradeReader {
+	return &TradeReader{newReader(r, tradeSchema, format, opt)}
+}
+
+// Read reads the next trade. It returns io.EOF at the end of input.
+func (r *TradeReader) Read() (*wallex.Trade, error) {
+	fields, err := r.r.read()
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{r: r.r, fields: fields}
+	t := &wallex.Trade{
+		Symbol:         fields[0],
+		Timestamp:      d.time(1),
+		Price:          d.number(2),
+		Quantity:       d.number(3),
+		Sum:            d.number(4),
+		Fee:            d.number(5),
+		FeeCoefficient: d.number(6),
+		FeeAsset:       fields[7],
+		IsBuyer:        d.bool(8),
+	}
+	return t, d.err
+}
+
+// ReadAll reads the remaining trades.
+func (r *TradeReader) ReadAll() ([]*wallex.Trade, error) {
+	var trades []*wallex.Trade
+	for {
+		t, err := r.Read()
+		if err == io.EOF {
+			return trades, nil
+		}
+		if err != nil {
+			return trades, err
+		}
+		trades = append(trades, t)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Orders
+// -----------------------------------------------------------------------------
+
+var orderSchema = []column{
+	{"client_order_id", kindString},
+	{"symbol", kindString},
+	{"type", kindString},
+	{"side", kindString},
+	{"price", kindNumber},
+	{"orig_qty", kindNumber},
+	{"orig_sum", kindNumber},
+	{"executed_price", kindOptionalNumber},
+	{"executed_qty", kindOptionalNumber},
+	{"executed_sum", kindOptionalNumber},
+	{"executed_percent", kindOptionalNumber},
+	{"status", kindString},
+	{"active", kindBool},
+	{"created_at", kindTime},
+}
+
+// OrderColumns are the columns of order files, in order.
+var OrderColumns = columnNames(orderSchema)
+
+// OrderWriter writes orders.
+type OrderWriter struct {
+	w *writer
+}
+
+// NewOrderWriter returns a writer of orders to w.
+// The caller must call Flush when done.
+func NewOrderWriter(w io.Writer, format Format, opt Options) *OrderWriter {
+	return &OrderWriter{newWriter(w, orderSchema, format, opt)}
+}
+
+// Write writes orders.
+func (w *OrderWriter) Write(orders ...*wallex.Order) error {
+	for _, o := range orders {
+		err := w.w.write([]string{
+			o.ClientOrderID,
+			o.Symbol,
+			o.Type,
+			o.Side,
+			string(o.Price),
+			string(o.OrigQty),
+			string(o.OrigSum),
+			formatOptionalNumber(o.ExecutedPrice),
+			formatOptionalNumber(o.ExecutedQty),
+			formatOptionalNumber(o.ExecutedSum),
+			formatOptionalNumber(o.ExecutedPercent),
+			o.Status,
+			formatBool(o.Active),
+			w.w.opt.formatTime(o.CreatedAt),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *OrderWriter) Flush() error {
+	return w.w.flush()
+}
+
+// OrderReader reads orders.
+type OrderReader struct {
+	r *reader
+}
+
+// NewOrderReader returns a reader of orders from r.
+func NewOrderReader(r io.Reader, format Format, opt Options) *OrderReader {
+	return &OrderReader{newReader(r, orderSchema, format, opt)}
+}
+
+// Read reads the next order. It returns io.EOF at the end of input.
+func (r *OrderReader) Read() (*wallex.Order, error) {
+	fields, err := r.r.read()
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{r: r.r, fields: fields}
+	o := &wallex.Order{
+		ClientOrderID:   fields[0],
+		Symbol:          fields[1],
+		Type:            fields[2],
+		Side:            fields[3],
+		Price:           d.number(4),
+		OrigQty:         d.number(5),
+		OrigSum:         d.number(6),
+		ExecutedPrice:   d.optionalNumber(7),
+		ExecutedQty:     d.optionalNumber(8),
+		ExecutedSum:     d.optionalNumber(9),
+		ExecutedPercent: d.optionalNumber(10),
+		Status:          fields[11],
+		Active:          d.bool(12),
+		CreatedAt:       d.time(13),
+	}
+	return o, d.err
+}
+
+// ReadAll reads the remaining orders.
+func (r *OrderReader) ReadAll() ([]*wallex.Order, error) {
+	var orders []*wallex.Order
+	for {
+		o, err := r.Read()
+		if err == io.EOF {
+			return orders, nil
+		}
+		if err != nil {
+			return orders, err
+		}
+		orders = append(orders, o)
+	}
+}
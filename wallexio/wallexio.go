@@ -0,0 +1,420 @@
+// Package wallexio reads and writes candles, trades and orders as CSV or
+// JSON Lines with stable schemas. Numbers are written as their exact
+// literals, so a written file reads back to the same values.
+package wallexio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/jalali"
+)
+
+// Format is a file format.
+type Format int
+
+// List of file formats.
+const (
+	// CSV is comma-separated values with a header row.
+	CSV Format = iota
+
+	// JSONLines is one JSON object per line. Numbers are JSON strings and
+	// fields are written in the same order as CSV columns.
+	JSONLines
+)
+
+// TimeFormat is how timestamps are written.
+type TimeFormat int
+
+// List of timestamp formats.
+const (
+	// TimeRFC3339 writes RFC 3339 timestamps with nanoseconds if any,
+	// e.g. "2023-03-21T12:30:00+03:30".
+	TimeRFC3339 TimeFormat = iota
+
+	// TimeUnix writes seconds since the Unix epoch, with a fraction if
+	// the timestamp has one.
+	TimeUnix
+
+	// TimeUnixMilli writes milliseconds since the Unix epoch, with a
+	// fraction if the timestamp has one.
+	TimeUnixMilli
+
+	// TimeJalali writes Jalali dates and times, e.g. "1402/01/01 12:30:00",
+	// in Location, which defaults to Tehran. Fractions of a second are
+	// dropped, and so is the offset, so a time in the hour repeated when
+	// clocks moved back may read back as the other occurrence.
+	TimeJalali
+)
+
+const jalaliLayout = "%Y/%m/%d %H:%M:%S"
+
+// Options customizes readers and writers.
+type Options struct {
+
+	// Time is the format of timestamps.
+	Time TimeFormat
+
+	// Location is the zone timestamps are written and read in.
+	// If nil, it defaults to Tehran for TimeJalali and UTC otherwise.
+	Location *time.Location
+}
+
+func (opt Options) location() *time.Location {
+	switch {
+	case opt.Location != nil:
+		return opt.Location
+	case opt.Time == TimeJalali:
+		return jalali.Tehran()
+	default:
+		return time.UTC
+	}
+}
+
+func (opt Options) formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	switch opt.Time {
+	case TimeUnix:
+		return unixString(t, 0)
+	case TimeUnixMilli:
+		return unixString(t, 3)
+	case TimeJalali:
+		return jalali.Format(t.In(opt.location()), jalaliLayout)
+	default:
+		return t.In(opt.location()).Format(time.RFC3339Nano)
+	}
+}
+
+func (opt Options) parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	switch opt.Time {
+	case TimeUnix:
+		return parseUnix(s, 0, opt.location())
+	case TimeUnixMilli:
+		return parseUnix(s, 3, opt.location())
+	case TimeJalali:
+		return jalali.Parse(jalaliLayout, s, opt.location())
+	default:
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.In(opt.location()), nil
+	}
+}
+
+// unixString formats t as a decimal number of 10^-scale seconds.
+func unixString(t time.Time, scale int) string {
+	r := new(big.Rat).SetFrac(big.NewInt(t.UnixNano()), big.NewInt(1e9))
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	return trimZeros(r.FloatString(9 - scale))
+}
+
+func trimZeros(s string) string {
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func parseUnix(s string, scale int, loc *time.Location) (time.Time, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid unix time %q", s)
+	}
+	r.Mul(r, big.NewRat(int64(1e9), pow10(scale)))
+	ns := new(big.Int).Quo(r.Num(), r.Denom())
+	if !ns.IsInt64() {
+		return time.Time{}, fmt.Errorf("unix time %q out of range", s)
+	}
+	return time.Unix(0, ns.Int64()).In(loc), nil
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// -----------------------------------------------------------------------------
+// Schemas
+// -----------------------------------------------------------------------------
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindNumber
+	kindOptionalNumber // A *wallex.Number, empty or null if nil.
+	kindTime
+	kindBool
+)
+
+type column struct {
+	name string
+	kind kind
+}
+
+func columnNames(columns []column) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// -----------------------------------------------------------------------------
+// Writer
+// -----------------------------------------------------------------------------
+
+// writer writes records of a schema. Fields of a record are encoded as
+// strings, empty for nil optional numbers and zero times.
+type writer struct {
+	columns []column
+	format  Format
+	opt     Options
+
+	w      *bufio.Writer
+	csv    *csv.Writer
+	header bool
+	buf    bytes.Buffer
+}
+
+func newWriter(w io.Writer, columns []column, format Format, opt Options) *writer {
+	bw := bufio.NewWriter(w)
+	wr := &writer{columns: columns, format: format, opt: opt, w: bw}
+	if format == CSV {
+		wr.csv = csv.NewWriter(bw)
+	}
+	return wr
+}
+
+func (w *writer) write(fields []string) error {
+	if w.format == CSV {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+		return w.csv.Write(fields)
+	}
+
+	w.buf.Reset()
+	w.buf.WriteByte('{')
+	for i, c := range w.columns {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(c.name)
+		w.buf.Write(name)
+		w.buf.WriteByte(':')
+		w.buf.Write(jsonValue(c, fields[i], w.opt))
+	}
+	w.buf.WriteString("}\n")
+	_, err := w.w.Write(w.buf.Bytes())
+	return err
+}
+
+func (w *writer) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	return w.csv.Write(columnNames(w.columns))
+}
+
+func (w *writer) flush() error {
+	if w.format == CSV {
+		// An empty file still gets its header.
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+func jsonValue(c column, s string, opt Options) []byte {
+	switch {
+	case c.kind == kindBool:
+		return []byte(s)
+	case c.kind == kindOptionalNumber && s == "":
+		return []byte("null")
+	case c.kind == kindTime && s == "":
+		return []byte("null")
+	case c.kind == kindTime && (opt.Time == TimeUnix || opt.Time == TimeUnixMilli):
+		return []byte(s)
+	}
+	v, _ := json.Marshal(s)
+	return v
+}
+
+// -----------------------------------------------------------------------------
+// Reader
+// -----------------------------------------------------------------------------
+
+// reader reads records of a schema, in the order of its columns.
+type reader struct {
+	columns []column
+	format  Format
+	opt     Options
+
+	csv   *csv.Reader
+	index []int // CSV field of each column.
+	err   error // Sticky error of an invalid header.
+	dec   *json.Decoder
+	line  int
+}
+
+func newReader(r io.Reader, columns []column, format Format, opt Options) *reader {
+	rd := &reader{columns: columns, format: format, opt: opt}
+	if format == CSV {
+		rd.csv = csv.NewReader(r)
+		rd.csv.ReuseRecord = true
+	} else {
+		rd.dec = json.NewDecoder(r)
+		rd.dec.UseNumber()
+	}
+	return rd
+}
+
+// read returns the fields of the next record, or io.EOF.
+func (r *reader) read() ([]string, error) {
+	r.line++
+	if r.format == CSV {
+		return r.readCSV()
+	}
+	return r.readJSON()
+}
+
+func (r *reader) readCSV() ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.index == nil {
+		header, err := r.csv.Read()
+		if err != nil {
+			return nil, err
+		}
+		pos := map[string]int{}
+		for i, name := range header {
+			pos[name] = i
+		}
+		// The index is only kept once all columns are found, so that a
+		// missing column fails every read rather than the first one.
+		index := make([]int, len(r.columns))
+		for i, c := range r.columns {
+			j, ok := pos[c.name]
+			if !ok {
+				r.err = fmt.Errorf("wallexio: missing column %q", c.name)
+				return nil, r.err
+			}
+			index[i] = j
+		}
+		r.index = index
+	}
+
+	record, err := r.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, len(r.columns))
+	for i, j := range r.index {
+		fields[i] = record[j]
+	}
+	return fields, nil
+}
+
+func (r *reader) readJSON() ([]string, error) {
+	var obj map[string]json.RawMessage
+	if err := r.dec.Decode(&obj); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("wallexio: line %d: %w", r.line, err)
+	}
+	fields := make([]string, len(r.columns))
+	for i, c := range r.columns {
+		raw, ok := obj[c.name]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			// Booleans and Unix timestamps are not quoted.
+			s = string(raw)
+		}
+		fields[i] = s
+	}
+	return fields, nil
+}
+
+// fieldError reports an invalid field of the current record.
+func (r *reader) fieldError(i int, err error) error {
+	return fmt.Errorf("wallexio: record %d: %s: %w", r.line, r.columns[i].name, err)
+}
+
+// decoder converts the fields of a record, remembering the first error.
+type decoder struct {
+	r      *reader
+	fields []string
+	err    error
+}
+
+func (d *decoder) number(i int) wallex.Number {
+	s := d.fields[i]
+	if s == "" {
+		return ""
+	}
+	n, err := wallex.ParseNumber(s)
+	if err != nil && d.err == nil {
+		d.err = d.r.fieldError(i, err)
+	}
+	return n
+}
+
+func (d *decoder) optionalNumber(i int) *wallex.Number {
+	if d.fields[i] == "" {
+		return nil
+	}
+	n := d.number(i)
+	return &n
+}
+
+func (d *decoder) time(i int) time.Time {
+	t, err := d.r.opt.parseTime(d.fields[i])
+	if err != nil && d.err == nil {
+		d.err = d.r.fieldError(i, err)
+	}
+	return t
+}
+
+func (d *decoder) bool(i int) bool {
+	if d.fields[i] == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(d.fields[i])
+	if err != nil && d.err == nil {
+		d.err = d.r.fieldError(i, errors.New("invalid boolean "+strconv.Quote(d.fields[i])))
+	}
+	return b
+}
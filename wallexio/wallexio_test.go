@@ -0,0 +1,286 @@
+package wallexio_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // Asia/Tehran without a system time zone database.
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/jalali"
+	"github.com/wallexchange/wallex-go/wallexio"
+)
+
+var formats = []struct {
+	name   string
+	format wallexio.Format
+}{
+	{"CSV", wallexio.CSV},
+	{"JSONLines", wallexio.JSONLines},
+}
+
+var timeFormats = []struct {
+	name string
+	opt  wallexio.Options
+}{
+	{"RFC3339", wallexio.Options{Time: wallexio.TimeRFC3339}},
+	{"RFC3339/Tehran", wallexio.Options{Time: wallexio.TimeRFC3339, Location: jalali.Tehran()}},
+	{"Unix", wallexio.Options{Time: wallexio.TimeUnix}},
+	{"UnixMilli", wallexio.Options{Time: wallexio.TimeUnixMilli}},
+	{"Jalali", wallexio.Options{Time: wallexio.TimeJalali}},
+	{"Jalali/UTC", wallexio.Options{Time: wallexio.TimeJalali, Location: time.UTC}},
+}
+
+// times returns timestamps to write with opt, in the zone they read back in.
+func times(opt wallexio.Options) []time.Time {
+	loc := opt.Location
+	switch {
+	case loc != nil:
+	case opt.Time == wallexio.TimeJalali:
+		loc = jalali.Tehran()
+	default:
+		loc = time.UTC
+	}
+	ts := []time.Time{
+		time.Date(2023, 3, 21, 12, 30, 0, 0, loc),
+		// Noon of the day Tehran clocks moved forward in 2011.
+		time.Date(2011, 3, 22, 12, 0, 0, 0, jalali.Tehran()).In(loc),
+		time.Date(1970, 1, 1, 0, 0, 0, 0, loc),
+		{},
+	}
+	if opt.Time != wallexio.TimeJalali {
+		ts = append(ts,
+			time.Date(2024, 2, 29, 23, 59, 59, 123456789, loc),
+			time.Date(1969, 12, 31, 23, 59, 59, 500000000, loc))
+	}
+	return ts
+}
+
+func number(s string) *wallex.Number {
+	n := wallex.Number(s)
+	return &n
+}
+
+func TestCandleRoundTrip(t *testing.T) {
+	for _, f := range formats {
+		for _, tf := range timeFormats {
+			var candles []*wallex.Candle
+			for _, ts := range times(tf.opt) {
+				candles = append(candles, &wallex.Candle{
+					Timestamp: ts,
+					Open:      "1.50",
+					High:      "2",
+					Low:       "0.000000012345678901234567890",
+					Close:     "-3e-2",
+					Volume:    "123456789012345678901234567890",
+				})
+			}
+			var buf bytes.Buffer
+			w := wallexio.NewCandleWriter(&buf, f.format, tf.opt)
+			if err := w.Write(candles...); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := wallexio.NewCandleReader(&buf, f.format, tf.opt).ReadAll()
+			if err != nil {
+				t.Fatalf("%s/%s: %v", f.name, tf.name, err)
+			}
+			if !reflect.DeepEqual(got, candles) {
+				t.Errorf("%s/%s: read back\n%v\nwant\n%v", f.name, tf.name, got, candles)
+			}
+		}
+	}
+}
+
+func TestMarketTradeRoundTrip(t *testing.T) {
+	for _, f := range formats {
+		for _, tf := range timeFormats {
+			var trades []*wallex.MarketTrade
+			for i, ts := range times(tf.opt) {
+				trades = append(trades, &wallex.MarketTrade{
+					Symbol:     "BTCTMN",
+					Timestamp:  ts,
+					Price:      "1000000000",
+					Quantity:   "0.00010",
+					Sum:        "100000.0000",
+					IsBuyOrder: i%2 == 0,
+				})
+			}
+			var buf bytes.Buffer
+			w := wallexio.NewMarketTradeWriter(&buf, f.format, tf.opt)
+			if err := w.Write(trades...); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := wallexio.NewMarketTradeReader(&buf, f.format, tf.opt).ReadAll()
+			if err != nil {
+				t.Fatalf("%s/%s: %v", f.name, tf.name, err)
+			}
+			if !reflect.DeepEqual(got, trades) {
+				t.Errorf("%s/%s: read back\n%v\nwant\n%v", f.name, tf.name, got, trades)
+			}
+		}
+	}
+}
+
+func TestTradeRoundTrip(t *testing.T) {
+	for _, f := range formats {
+		for _, tf := range timeFormats {
+			var trades []*wallex.Trade
+			for i, ts := range times(tf.opt) {
+				trades = append(trades, &wallex.Trade{
+					Symbol:         "USDTTMN",
+					Timestamp:      ts,
+					Price:          "61500",
+					Quantity:       "12.5",
+					Sum:            "768750",
+					Fee:            "0.0125",
+					FeeCoefficient: "0.001",
+					FeeAsset:       "USDT, \"quoted\"",
+					IsBuyer:        i%2 == 1,
+				})
+			}
+			var buf bytes.Buffer
+			w := wallexio.NewTradeWriter(&buf, f.format, tf.opt)
+			if err := w.Write(trades...); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := wallexio.NewTradeReader(&buf, f.format, tf.opt).ReadAll()
+			if err != nil {
+				t.Fatalf("%s/%s: %v", f.name, tf.name, err)
+			}
+			if !reflect.DeepEqual(got, trades) {
+				t.Errorf("%s/%s: read back\n%v\nwant\n%v", f.name, tf.name, got, trades)
+			}
+		}
+	}
+}
+
+func TestOrderRoundTrip(t *testing.T) {
+	for _, f := range formats {
+		for _, tf := range timeFormats {
+			var orders []*wallex.Order
+			for i, ts := range times(tf.opt) {
+				o := &wallex.Order{
+					ClientOrderID: "order-" + string(rune('a'+i)),
+					Symbol:        "BTCTMN",
+					Type:          "LIMIT",
+					Side:          "BUY",
+					Price:         "1000000000",
+					OrigQty:       "0.5",
+					OrigSum:       "500000000",
+					Status:        "NEW",
+					Active:        true,
+					CreatedAt:     ts,
+				}
+				// Every other order is filled; the rest keep nil numbers.
+				if i%2 == 1 {
+					o.ExecutedPrice = number("999999999.5")
+					o.ExecutedQty = number("0.5")
+					o.ExecutedSum = number("499999999.75")
+					o.ExecutedPercent = number("100")
+					o.Status = "FILLED"
+					o.Active = false
+				}
+				orders = append(orders, o)
+			}
+			var buf bytes.Buffer
+			w := wallexio.NewOrderWriter(&buf, f.format, tf.opt)
+			if err := w.Write(orders...); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := wallexio.NewOrderReader(&buf, f.format, tf.opt).ReadAll()
+			if err != nil {
+				t.Fatalf("%s/%s: %v", f.name, tf.name, err)
+			}
+			if !reflect.DeepEqual(got, orders) {
+				t.Errorf("%s/%s: read back\n%v\nwant\n%v", f.name, tf.name, got, orders)
+			}
+		}
+	}
+}
+
+func TestJSONLinesNullNumbers(t *testing.T) {
+	var buf bytes.Buffer
+	w := wallexio.NewOrderWriter(&buf, wallexio.JSONLines, wallexio.Options{})
+	w.Write(&wallex.Order{Symbol: "BTCTMN", ExecutedQty: number("0")})
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	line := buf.String()
+	for _, want := range []string{`"executed_price":null`, `"executed_qty":"0"`, `"created_at":null`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("%s does not contain %s", line, want)
+		}
+	}
+}
+
+func TestEmptyCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := wallexio.NewCandleWriter(&buf, wallexio.CSV, wallexio.Options{}).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), strings.Join(wallexio.CandleColumns, ",")+"\n"; got != want {
+		t.Errorf("empty file = %q, want %q", got, want)
+	}
+	if _, err := wallexio.NewCandleReader(&buf, wallexio.CSV, wallexio.Options{}).Read(); err != io.EOF {
+		t.Errorf("Read = %v, want io.EOF", err)
+	}
+}
+
+func TestCSVColumnOrder(t *testing.T) {
+	data := "volume,close,low,high,open,timestamp,extra\n5,4,3,2,1,0,x\n"
+	got, err := wallexio.NewCandleReader(strings.NewReader(data), wallexio.CSV, wallexio.Options{Time: wallexio.TimeUnix}).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*wallex.Candle{{Timestamp: time.Unix(0, 0).UTC(), Open: "1", High: "2", Low: "3", Close: "4", Volume: "5"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCSVMissingColumn(t *testing.T) {
+	data := "timestamp,open,high,low,close\n0,1,2,3,4\n0,1,2,3,4\n"
+	r := wallexio.NewCandleReader(strings.NewReader(data), wallexio.CSV, wallexio.Options{Time: wallexio.TimeUnix})
+	_, err := r.Read()
+	if err == nil || !strings.Contains(err.Error(), `"volume"`) {
+		t.Fatalf("Read = %v, want a missing volume column", err)
+	}
+	// The error sticks instead of reading records with a partial header.
+	if _, err2 := r.Read(); !errors.Is(err2, err) {
+		t.Errorf("second Read = %v, want %v", err2, err)
+	}
+}
+
+func TestInvalidFields(t *testing.T) {
+	tests := []struct {
+		format wallexio.Format
+		data   string
+	}{
+		{wallexio.CSV, "timestamp,open,high,low,close,volume\n0,1,2,3,4,\"1,000\"\n"},
+		{wallexio.CSV, "timestamp,open,high,low,close,volume\nyesterday,1,2,3,4,5\n"},
+		{wallexio.JSONLines, `{"timestamp":0,"open":"abc"}` + "\n"},
+		{wallexio.JSONLines, "{\n"},
+	}
+	for _, tt := range tests {
+		r := wallexio.NewCandleReader(strings.NewReader(tt.data), tt.format, wallexio.Options{Time: wallexio.TimeUnix})
+		if c, err := r.Read(); err == nil {
+			t.Errorf("Read(%q) = %v, want an error", tt.data, c)
+		}
+	}
+}
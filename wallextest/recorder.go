@@ -0,0 +1,202 @@
+package wallextest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/wallexchange/wallex-go/internal/redact"
+)
+
+// Mode is the operating mode of a Recorder.
+type Mode int
+
+// List of recorder modes.
+const (
+	// ModeReplay serves responses from the fixture file only.
+	ModeReplay Mode = iota
+
+	// ModeRecord sends requests to the real API and records the responses.
+	ModeRecord
+
+	// ModeAuto replays if the fixture file exists and records otherwise.
+	ModeAuto
+)
+
+// recordedHeaders are the response headers kept in fixtures.
+// Everything else, such as cookies, is dropped.
+var recordedHeaders = []string{"Content-Type", "Retry-After"}
+
+// Interaction is a recorded request and its response.
+// Requests are matched on method, path and query.
+type Interaction struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Recorder is an http.RoundTripper that records responses of the real API
+// into a fixture file and replays them later. Request headers, including the
+// API key, are never recorded and personal information in response bodies is
+// scrubbed before recording.
+type Recorder struct {
+	path      string
+	mode      Mode
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	replayed     map[string]int
+}
+
+// NewRecorder instantiates a new Recorder for the fixture file at path.
+// In record mode, requests are sent using transport, which defaults to
+// http.DefaultTransport if nil.
+func NewRecorder(path string, mode Mode, transport http.RoundTripper) (*Recorder, error) {
+	if mode == ModeAuto {
+		mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			mode = ModeReplay
+		}
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: transport,
+		replayed:  map[string]int{},
+	}
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("wallextest: load fixture: %w", err)
+		}
+		if err := json.Unmarshal(data, &r.interactions); err != nil {
+			return nil, fmt.Errorf("wallextest: load fixture %s: %w", path, err)
+		}
+	}
+	return r, nil
+}
+
+// Mode returns the effective mode of r.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModeReplay {
+		return r.replay(req)
+	}
+	return r.record(req)
+}
+
+// Save writes the recorded interactions to the fixture file.
+// It does nothing in replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("wallextest: save fixture: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("wallextest: save fixture: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("wallextest: save fixture: %w", err)
+	}
+	return nil
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Interaction{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.Query().Encode(),
+		Status: resp.StatusCode,
+		Header: http.Header{},
+		Body:   string(redact.JSON(body)),
+	}
+	for _, h := range recordedHeaders {
+		if v := resp.Header.Values(h); len(v) > 0 {
+			i.Header[h] = v
+		}
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, i)
+	r.mu.Unlock()
+
+	// The caller gets the scrubbed response too, so that recording and
+	// replaying runs observe the same data.
+	return i.response(req), nil
+}
+
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	method, path, query := req.Method, req.URL.Path, req.URL.Query().Encode()
+	key := method + " " + path + "?" + query
+
+	// Identical requests replay their responses in recorded order,
+	// repeating the last one once exhausted.
+	var matches []*Interaction
+	for _, i := range r.interactions {
+		if i.Method == method && i.Path == path && i.Query == query {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("wallextest: no recorded response for %s", key)
+	}
+	n := r.replayed[key]
+	r.replayed[key]++
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	return matches[n].response(req), nil
+}
+
+func (i *Interaction) response(req *http.Request) *http.Response {
+	header := http.Header{}
+	for k, v := range i.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(i.Body))),
+		ContentLength: int64(len(i.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,104 @@
+package wallextest_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/wallextest"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures", "api.json")
+
+	// Record against a fake of the real API.
+	srv := wallextest.NewServer()
+	srv.SetProfile(&wallex.Profile{TrackingID: 7654321, FirstName: "Sara", NationalCode: "0012345678", Verification: "verified"})
+	srv.SetMarketTrades("BTCTMN", &wallex.MarketTrade{Symbol: "BTCTMN", Price: "1"})
+	srv.SetMarketTrades("USDTTMN", &wallex.MarketTrade{Symbol: "USDTTMN", Price: "2"})
+	srv.Fail("/v1/markets", wallextest.Failure{Status: http.StatusServiceUnavailable, Times: 1})
+
+	rec, err := wallextest.NewRecorder(path, wallextest.ModeAuto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != wallextest.ModeRecord {
+		t.Fatalf("mode = %v without a fixture, want ModeRecord", rec.Mode())
+	}
+	c := srv.Client(wallex.ClientOptions{HTTPClient: &http.Client{Transport: rec}})
+	p, err := c.Profile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The caller already sees the scrubbed profile.
+	if p.FirstName != "[REDACTED]" || p.TrackingID != 0 || p.Verification != "verified" {
+		t.Errorf("recorded Profile = %+v", p)
+	}
+	for _, symbol := range []string{"BTCTMN", "USDTTMN"} {
+		if _, err := c.MarketTrades(symbol); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Markets(); err == nil {
+		t.Fatal("injected failure is not recorded")
+	}
+	if _, err := c.Markets(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"Sara", "7654321", "0012345678", wallextest.DefaultAPIKey} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("fixture contains %q", secret)
+		}
+	}
+
+	// Replay with the server gone.
+	rec, err = wallextest.NewRecorder(path, wallextest.ModeAuto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != wallextest.ModeReplay {
+		t.Fatalf("mode = %v with a fixture, want ModeReplay", rec.Mode())
+	}
+	c = wallex.New(wallex.ClientOptions{
+		BaseURL:    srv.URL,
+		APIKey:     "another-key",
+		HTTPClient: &http.Client{Transport: rec},
+	})
+	if p, err := c.Profile(); err != nil || p.FirstName != "[REDACTED]" || p.TrackingID != 0 {
+		t.Errorf("replayed Profile = %+v, %v", p, err)
+	}
+	// Requests are matched on their query too.
+	for _, tt := range []struct {
+		symbol string
+		price  wallex.Number
+	}{{"USDTTMN", "2"}, {"BTCTMN", "1"}} {
+		trades, err := c.MarketTrades(tt.symbol)
+		if err != nil || len(trades) != 1 || trades[0].Price != tt.price {
+			t.Errorf("replayed MarketTrades(%s) = %v, %v", tt.symbol, trades, err)
+		}
+	}
+	if _, err := c.MarketTrades("ETHTMN"); err == nil {
+		t.Error("unrecorded request is replayed")
+	}
+	// Identical requests replay in order, repeating the last response.
+	if _, err := c.Markets(); err == nil {
+		t.Error("first replayed Markets succeeded, want the recorded failure")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Markets(); err != nil {
+			t.Errorf("replayed Markets %d = %v", i+2, err)
+		}
+	}
+}
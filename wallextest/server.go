@@ -0,0 +1,660 @@
+// Package wallextest provides an in-memory fake of Wallex API
+// for hermetic tests of code that uses wallex.Client.
+package wallextest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	wallex "github.com/wallexchange/wallex-go"
+)
+
+// DefaultAPIKey is the API key a new Server accepts.
+const DefaultAPIKey = "wallextest-api-key"
+
+// Failure is an injected failure of an endpoint.
+type Failure struct {
+
+	// Method restricts the failure to requests with this method.
+	// If empty, it matches all methods.
+	Method string
+
+	// Status is the response status code.
+	// If zero, the connection is closed without a response.
+	Status int
+
+	// Body is the response body.
+	// If empty, a Wallex error envelope with the status text is sent.
+	Body string
+
+	// Header is added to the response, e.g. Retry-After.
+	Header http.Header
+
+	// Delay is waited before responding.
+	Delay time.Duration
+
+	// Times is the number of requests that fail.
+	// If zero, all requests fail until the failure is cleared.
+	Times int
+}
+
+type orderBook struct {
+	ask []*wallex.MarketOrder
+	bid []*wallex.MarketOrder
+}
+
+// Server is a fake Wallex API served by an httptest.Server.
+// Its state is programmed with setter methods and is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	apiKey       string
+	markets      map[string]*wallex.Market
+	currencies   []*wallex.Currency
+	depth        map[string]*orderBook
+	marketTrades map[string][]*wallex.MarketTrade
+	candles      map[string][]*wallex.Candle
+	profile      *wallex.Profile
+	balances     map[string]*wallex.Balance
+	feeLevels    map[string]*wallex.FeeLevel
+	cards        []*wallex.BankingCard
+	accounts     []*wallex.BankAccount
+	orders       map[string]*wallex.Order
+	orderSeq     int
+	trades       []*wallex.Trade
+	failures     map[string][]*Failure
+	hits         map[string]int
+	streams      map[*streamConn]bool
+	streamSeq    int
+	pingInterval time.Duration
+}
+
+// NewServer starts a new fake server with empty state.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		apiKey:       DefaultAPIKey,
+		markets:      map[string]*wallex.Market{},
+		depth:        map[string]*orderBook{},
+		marketTrades: map[string][]*wallex.MarketTrade{},
+		candles:      map[string][]*wallex.Candle{},
+		balances:     map[string]*wallex.Balance{},
+		feeLevels:    map[string]*wallex.FeeLevel{},
+		orders:       map[string]*wallex.Order{},
+		failures:     map[string][]*Failure{},
+		hits:         map[string]int{},
+		streams:      map[*streamConn]bool{},
+		pingInterval: 25 * time.Second,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/markets", s.public(s.handleMarkets))
+	mux.HandleFunc("/v1/currencies/stats", s.public(s.handleCurrencies))
+	mux.HandleFunc("/v1/depth", s.public(s.handleDepth))
+	mux.HandleFunc("/v1/trades", s.public(s.handleMarketTrades))
+	mux.HandleFunc("/v1/udf/history", s.public(s.handleCandles))
+	mux.HandleFunc("/v1/account/profile", s.private(s.handleProfile))
+	mux.HandleFunc("/v1/account/balances", s.private(s.handleBalances))
+	mux.HandleFunc("/v1/account/fee", s.private(s.handleFeeLevels))
+	mux.HandleFunc("/v1/account/card-numbers", s.private(s.handleBankingCards))
+	mux.HandleFunc("/v1/account/ibans", s.private(s.handleBankAccounts))
+	mux.HandleFunc("/v1/account/orders", s.private(s.handleOrders))
+	mux.HandleFunc("/v1/account/orders/", s.private(s.handleOrder))
+	mux.HandleFunc("/v1/account/openOrders", s.private(s.handleOpenOrders))
+	mux.HandleFunc("/v1/account/trades", s.private(s.handleTrades))
+	mux.HandleFunc("/socket.io/", s.handleStream)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Client returns a client configured to talk to s.
+// BaseURL, StreamURL and APIKey of opt are set unless already given.
+func (s *Server) Client(opt wallex.ClientOptions) *wallex.Client {
+	if opt.BaseURL == "" {
+		opt.BaseURL = s.URL
+	}
+	if opt.StreamURL == "" {
+		opt.StreamURL = s.StreamURL()
+	}
+	if opt.APIKey == "" {
+		opt.APIKey = s.APIKey()
+	}
+	return wallex.New(opt)
+}
+
+// APIKey returns the API key accepted by private endpoints.
+func (s *Server) APIKey() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.apiKey
+}
+
+// SetAPIKey sets the API key accepted by private endpoints.
+func (s *Server) SetAPIKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = key
+}
+
+// SetMarkets replaces the listed markets.
+func (s *Server) SetMarkets(markets ...*wallex.Market) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.markets = map[string]*wallex.Market{}
+	for _, m := range markets {
+		s.markets[m.Symbol] = m
+	}
+}
+
+// SetCurrencies replaces the currency stats.
+func (s *Server) SetCurrencies(currencies ...*wallex.Currency) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currencies = currencies
+}
+
+// SetMarketOrders replaces the order book of a market.
+func (s *Server) SetMarketOrders(symbol string, ask, bid []*wallex.MarketOrder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.depth[symbol] = &orderBook{ask: ask, bid: bid}
+}
+
+// SetMarketTrades replaces the latest trades of a market.
+func (s *Server) SetMarketTrades(symbol string, trades ...*wallex.MarketTrade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marketTrades[symbol] = trades
+}
+
+// SetCandles replaces the candles of a market in a resolution.
+func (s *Server) SetCandles(symbol string, resolution wallex.Resolution, candles ...*wallex.Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	candles = append([]*wallex.Candle(nil), candles...)
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Timestamp.Before(candles[j].Timestamp)
+	})
+	s.candles[symbol+"|"+string(resolution)] = candles
+}
+
+// SetProfile replaces the account profile.
+func (s *Server) SetProfile(p *wallex.Profile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profile = p
+}
+
+// SetBalances replaces the account balances.
+func (s *Server) SetBalances(balances ...*wallex.Balance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances = map[string]*wallex.Balance{}
+	for _, b := range balances {
+		s.balances[b.Asset] = b
+	}
+}
+
+// SetFeeLevels replaces the fee levels per symbol.
+func (s *Server) SetFeeLevels(levels map[string]*wallex.FeeLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feeLevels = levels
+}
+
+// SetBankingCards replaces the account banking cards.
+func (s *Server) SetBankingCards(cards ...*wallex.BankingCard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cards = cards
+}
+
+// SetBankAccounts replaces the account bank accounts.
+func (s *Server) SetBankAccounts(accounts ...*wallex.BankAccount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts = accounts
+}
+
+// SetTrades replaces the account trades.
+func (s *Server) SetTrades(trades ...*wallex.Trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trades = trades
+}
+
+// AddOrder adds a copy of o as if it was placed. If o has no client order
+// id, one is assigned to o.
+func (s *Server) AddOrder(o *wallex.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o.ClientOrderID == "" {
+		o.ClientOrderID = s.nextOrderID()
+	}
+	s.orders[o.ClientOrderID] = copyOrder(o)
+}
+
+// Orders returns copies of all orders, placed or added, sorted by creation
+// time.
+func (s *Server) Orders() []*wallex.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := s.sortedOrders()
+	for i, o := range orders {
+		orders[i] = copyOrder(o)
+	}
+	return orders
+}
+
+// Fail injects a failure to the endpoint at path, e.g. "/v1/markets".
+// Failures of a path are consumed in the order they are injected.
+func (s *Server) Fail(path string, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], &f)
+}
+
+// ClearFailures removes all injected failures.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = map[string][]*Failure{}
+}
+
+// Hits returns the number of requests received at path.
+func (s *Server) Hits(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[path]
+}
+
+// -----------------------------------------------------------------------------
+// Handlers
+// -----------------------------------------------------------------------------
+
+// handler serves a request with s.mu held.
+type handler func(w http.ResponseWriter, r *http.Request)
+
+func (s *Server) public(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.intercept(w, r) {
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		h(w, r)
+	}
+}
+
+func (s *Server) private(h handler) http.HandlerFunc {
+	return s.public(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != s.apiKey {
+			writeError(w, http.StatusUnauthorized, "Unauthenticated.", nil)
+			return
+		}
+		h(w, r)
+	})
+}
+
+// intercept counts the request and serves an injected failure, if any.
+// It reports whether the request was served.
+func (s *Server) intercept(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	path := r.URL.Path
+	s.hits[path]++
+	var f *Failure
+	for i, candidate := range s.failures[path] {
+		if candidate.Method != "" && candidate.Method != r.Method {
+			continue
+		}
+		f = candidate
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.failures[path] = append(s.failures[path][:i:i], s.failures[path][i+1:]...)
+			}
+		}
+		break
+	}
+	var copied Failure
+	if f != nil {
+		copied = *f
+	}
+	s.mu.Unlock()
+
+	if f == nil {
+		return false
+	}
+	if copied.Delay > 0 {
+		select {
+		case <-time.After(copied.Delay):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if copied.Status == 0 {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return true
+			}
+		}
+		copied.Status = http.StatusInternalServerError
+	}
+	for k, vs := range copied.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	if copied.Body != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(copied.Status)
+		fmt.Fprint(w, copied.Body)
+		return true
+	}
+	writeError(w, copied.Status, http.StatusText(copied.Status), nil)
+	return true
+}
+
+func (s *Server) handleMarkets(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, map[string]interface{}{"symbols": s.markets})
+}
+
+func (s *Server) handleCurrencies(w http.ResponseWriter, r *http.Request) {
+	currencies := s.currencies
+	if currencies == nil {
+		currencies = []*wallex.Currency{}
+	}
+	writeResult(w, currencies)
+}
+
+func (s *Server) handleDepth(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	book, ok := s.depth[symbol]
+	if !ok {
+		if _, listed := s.markets[symbol]; !listed {
+			writeError(w, http.StatusBadRequest, "The given data was invalid.", map[string][]string{
+				"symbol": {"The selected symbol is invalid."},
+			})
+			return
+		}
+		book = &orderBook{}
+	}
+	writeResult(w, map[string]interface{}{
+		"ask": nonNil(book.ask),
+		"bid": nonNil(book.bid),
+	})
+}
+
+func (s *Server) handleMarketTrades(w http.ResponseWriter, r *http.Request) {
+	trades := s.marketTrades[r.URL.Query().Get("symbol")]
+	if trades == nil {
+		trades = []*wallex.MarketTrade{}
+	}
+	writeResult(w, map[string]interface{}{"latestTrades": trades})
+}
+
+func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, err1 := strconv.ParseInt(q.Get("from"), 10, 64)
+	to, err2 := strconv.ParseInt(q.Get("to"), 10, 64)
+	if err1 != nil || err2 != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"s": "error", "errmsg": "invalid range"})
+		return
+	}
+
+	result := struct {
+		S string   `json:"s"`
+		T []int64  `json:"t"`
+		O []string `json:"o"`
+		H []string `json:"h"`
+		L []string `json:"l"`
+		C []string `json:"c"`
+		V []string `json:"v"`
+	}{S: "ok"}
+	for _, c := range s.candles[q.Get("symbol")+"|"+q.Get("resolution")] {
+		t := c.Timestamp.Unix()
+		if t < from || t > to {
+			continue
+		}
+		result.T = append(result.T, t)
+		result.O = append(result.O, string(c.Open))
+		result.H = append(result.H, string(c.High))
+		result.L = append(result.L, string(c.Low))
+		result.C = append(result.C, string(c.Close))
+		result.V = append(result.V, string(c.Volume))
+	}
+	if len(result.T) == 0 {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"s": "no_data"})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	if s.profile == nil {
+		writeResult(w, &wallex.Profile{})
+		return
+	}
+	writeResult(w, s.profile)
+}
+
+func (s *Server) handleBalances(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, map[string]interface{}{"balances": s.balances})
+}
+
+func (s *Server) handleFeeLevels(w http.ResponseWriter, r *http.Request) {
+	levels := s.feeLevels
+	if levels == nil {
+		levels = map[string]*wallex.FeeLevel{}
+	}
+	writeResult(w, levels)
+}
+
+func (s *Server) handleBankingCards(w http.ResponseWriter, r *http.Request) {
+	cards := s.cards
+	if cards == nil {
+		cards = []*wallex.BankingCard{}
+	}
+	writeResult(w, cards)
+}
+
+func (s *Server) handleBankAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts := s.accounts
+	if accounts == nil {
+		accounts = []*wallex.BankAccount{}
+	}
+	writeResult(w, accounts)
+}
+
+func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.placeOrder(w, r)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("clientOrderId")
+		o, ok := s.orders[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Order not found.", nil)
+			return
+		}
+		o.Status = "CANCELED"
+		o.Active = false
+		writeResult(w, o)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.", nil)
+	}
+}
+
+func (s *Server) placeOrder(w http.ResponseWriter, r *http.Request) {
+	var p wallex.OrderParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, "Malformed request body.", nil)
+		return
+	}
+
+	fields := map[string][]string{}
+	if _, ok := s.markets[p.Symbol]; !ok && len(s.markets) > 0 {
+		fields["symbol"] = []string{"The selected symbol is invalid."}
+	}
+	if p.Type != wallex.OrderTypeLimit && p.Type != wallex.OrderTypeMarket {
+		fields["type"] = []string{"The selected type is invalid."}
+	}
+	if p.Side != wallex.OrderSideBuy && p.Side != wallex.OrderSideSell {
+		fields["side"] = []string{"The selected side is invalid."}
+	}
+	if p.Quantity.Float() <= 0 {
+		fields["quantity"] = []string{"The quantity must be greater than 0."}
+	}
+	if p.Type == wallex.OrderTypeLimit && p.Price.Float() <= 0 {
+		fields["price"] = []string{"The price must be greater than 0."}
+	}
+	if len(fields) > 0 {
+		writeError(w, http.StatusUnprocessableEntity, "The given data was invalid.", fields)
+		return
+	}
+
+	// Orders are deduplicated by client id, as the real API does.
+	if o, ok := s.orders[p.ClientID]; ok && p.ClientID != "" {
+		writeResult(w, o)
+		return
+	}
+
+	o := &wallex.Order{
+		Symbol:        p.Symbol,
+		Type:          p.Type,
+		Side:          p.Side,
+		Price:         p.Price,
+		OrigQty:       p.Quantity,
+		Status:        "NEW",
+		Active:        true,
+		ClientOrderID: p.ClientID,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if o.ClientOrderID == "" {
+		o.ClientOrderID = s.nextOrderID()
+	}
+	if p.Type == wallex.OrderTypeMarket {
+		o.Status = "FILLED"
+		o.Active = false
+		executed := p.Quantity
+		o.ExecutedQty = &executed
+	}
+	s.orders[o.ClientOrderID] = o
+	writeResult(w, o)
+}
+
+func (s *Server) handleOrder(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/account/orders/")
+	o, ok := s.orders[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Order not found.", nil)
+		return
+	}
+	writeResult(w, o)
+}
+
+func (s *Server) handleOpenOrders(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	orders := []*wallex.Order{}
+	for _, o := range s.sortedOrders() {
+		if o.Active && (symbol == "" || o.Symbol == symbol) {
+			orders = append(orders, o)
+		}
+	}
+	writeResult(w, map[string]interface{}{"orders": orders})
+}
+
+func (s *Server) handleTrades(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	symbol, side := q.Get("symbol"), q.Get("side")
+	trades := []*wallex.Trade{}
+	for _, t := range s.trades {
+		if symbol != "" && t.Symbol != symbol {
+			continue
+		}
+		if side != "" && t.IsBuyer != (side == wallex.OrderSideBuy) {
+			continue
+		}
+		trades = append(trades, t)
+	}
+	writeResult(w, map[string]interface{}{"AccountLatestTrades": trades})
+}
+
+func (s *Server) nextOrderID() string {
+	s.orderSeq++
+	return "wallextest-" + strconv.Itoa(s.orderSeq)
+}
+
+// copyOrder returns a copy of o that shares no memory with it, since
+// handlers update orders while tests may read them.
+func copyOrder(o *wallex.Order) *wallex.Order {
+	c := *o
+	for _, n := range []**wallex.Number{&c.ExecutedPrice, &c.ExecutedQty, &c.ExecutedSum, &c.ExecutedPercent} {
+		if *n != nil {
+			v := **n
+			*n = &v
+		}
+	}
+	return &c
+}
+
+func (s *Server) sortedOrders() []*wallex.Order {
+	orders := make([]*wallex.Order, 0, len(s.orders))
+	for _, o := range s.orders {
+		orders = append(orders, o)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+		}
+		return orders[i].ClientOrderID < orders[j].ClientOrderID
+	})
+	return orders
+}
+
+// -----------------------------------------------------------------------------
+// Responses
+// -----------------------------------------------------------------------------
+
+func nonNil(orders []*wallex.MarketOrder) []*wallex.MarketOrder {
+	if orders == nil {
+		return []*wallex.MarketOrder{}
+	}
+	return orders
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "The operation was successful",
+		"result":  result,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string, fields map[string][]string) {
+	var result interface{} = map[string]interface{}{}
+	if fields != nil {
+		result = fields
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"success": false,
+		"code":    status,
+		"message": message,
+		"result":  result,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
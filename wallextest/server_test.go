@@ -0,0 +1,338 @@
+package wallextest_test
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/wallextest"
+)
+
+func newServer(t *testing.T) (*wallextest.Server, *wallex.Client) {
+	srv := wallextest.NewServer()
+	t.Cleanup(srv.Close)
+	return srv, srv.Client(wallex.ClientOptions{})
+}
+
+func TestMarketData(t *testing.T) {
+	srv, c := newServer(t)
+	btc := &wallex.Market{Symbol: "BTCTMN", BaseAsset: "BTC", QuoteAsset: "TMN", MinQty: "0.0001"}
+	btc.Stats.LastPrice = "1000000000"
+	srv.SetMarkets(btc, &wallex.Market{Symbol: "USDTTMN"})
+	srv.SetCurrencies(&wallex.Currency{Key: "BTC", Price: "65000.5"})
+	ask := []*wallex.MarketOrder{{Price: "1000000010", Quantity: "0.5", Sum: "500000005"}}
+	bid := []*wallex.MarketOrder{{Price: "999999990", Quantity: "1", Sum: "999999990"}}
+	srv.SetMarketOrders("BTCTMN", ask, bid)
+	trades := []*wallex.MarketTrade{{Symbol: "BTCTMN", Price: "1000000000", Quantity: "0.1", Sum: "100000000", IsBuyOrder: true,
+		Timestamp: time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)}}
+	srv.SetMarketTrades("BTCTMN", trades...)
+
+	markets, err := c.Markets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(markets) != 2 || markets[0].Symbol != "BTCTMN" || markets[0].MinQty != "0.0001" ||
+		markets[0].Stats.LastPrice != "1000000000" || markets[1].Symbol != "USDTTMN" {
+		t.Errorf("Markets = %+v", markets)
+	}
+
+	currencies, err := c.Currencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(currencies) != 1 || currencies[0].Key != "BTC" || currencies[0].Price != "65000.5" {
+		t.Errorf("Currencies = %+v", currencies)
+	}
+
+	gotAsk, gotBid, err := c.MarketOrders("BTCTMN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotAsk, ask) || !reflect.DeepEqual(gotBid, bid) {
+		t.Errorf("MarketOrders = %+v, %+v", gotAsk, gotBid)
+	}
+	// A listed market without a book has an empty one.
+	if gotAsk, gotBid, err := c.MarketOrders("USDTTMN"); err != nil || len(gotAsk) != 0 || len(gotBid) != 0 {
+		t.Errorf("MarketOrders(USDTTMN) = %v, %v, %v", gotAsk, gotBid, err)
+	}
+	_, _, err = c.MarketOrders("NOPE")
+	var e *wallex.Error
+	if !errors.Is(err, wallex.ErrBadRequest) || !errors.As(err, &e) || len(e.Fields["symbol"]) == 0 {
+		t.Errorf("MarketOrders(NOPE) = %v, want a bad request on symbol", err)
+	}
+
+	gotTrades, err := c.MarketTrades("BTCTMN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotTrades) != 1 || gotTrades[0].Price != "1000000000" || !gotTrades[0].IsBuyOrder ||
+		!gotTrades[0].Timestamp.Equal(trades[0].Timestamp) {
+		t.Errorf("MarketTrades = %+v", gotTrades)
+	}
+}
+
+func TestCandles(t *testing.T) {
+	srv, c := newServer(t)
+	start := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	var candles []*wallex.Candle
+	for i := 0; i < 3; i++ {
+		candles = append(candles, &wallex.Candle{
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Open:      "1", High: "3", Low: "0.5", Close: "2", Volume: "10.25",
+		})
+	}
+	srv.SetCandles("BTCTMN", wallex.Hour, candles...)
+
+	got, err := c.Candles("BTCTMN", wallex.Hour, start, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d candles, want 3", len(got))
+	}
+	for i, cd := range got {
+		if !cd.Timestamp.Equal(candles[i].Timestamp) || cd.Volume != "10.25" || cd.Low != "0.5" {
+			t.Errorf("candle %d = %+v", i, cd)
+		}
+	}
+
+	_, err = c.Candles("BTCTMN", wallex.Hour, start.AddDate(0, 0, 1), start.AddDate(0, 0, 2))
+	if !errors.Is(err, wallex.ErrNoData) {
+		t.Errorf("Candles of an empty range = %v, want ErrNoData", err)
+	}
+}
+
+func TestAccount(t *testing.T) {
+	srv, c := newServer(t)
+	srv.SetProfile(&wallex.Profile{TrackingID: 42, FirstName: "Sara"})
+	srv.SetBalances(&wallex.Balance{Asset: "BTC", Value: "0.5", Locked: "0.1"}, &wallex.Balance{Asset: "TMN", Fiat: true, Value: "1000"})
+	srv.SetFeeLevels(map[string]*wallex.FeeLevel{"BTCTMN": {
+		Levels:   map[wallex.Number]*wallex.NamedFeeLevel{"1": {MakerFee: "0.2", TakerFee: "0.25"}},
+		MakerFee: "0.2",
+	}})
+	srv.SetBankingCards(&wallex.BankingCard{ID: 1, CardNumber: "6037-99xx-xxxx-1234", Owners: []string{"Sara"}})
+	srv.SetBankAccounts(&wallex.BankAccount{ID: 2, IBAN: "IR000000000000000000000001", BankName: "Melli"})
+
+	p, err := c.Profile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TrackingID != 42 || p.FirstName != "Sara" {
+		t.Errorf("Profile = %+v", p)
+	}
+
+	balances, err := c.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 2 || balances["BTC"].Locked != "0.1" || !balances["TMN"].Fiat {
+		t.Errorf("Balances = %+v", balances)
+	}
+
+	levels, err := c.FeeLevels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := levels["BTCTMN"]; l == nil || l.MakerFee != "0.2" || l.Levels["1"] == nil || l.Levels["1"].TakerFee != "0.25" {
+		t.Errorf("FeeLevels = %+v", levels)
+	}
+
+	cards, err := c.BankingCards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 1 || cards[0].CardNumber != "6037-99xx-xxxx-1234" || !reflect.DeepEqual(cards[0].Owners, []string{"Sara"}) {
+		t.Errorf("BankingCards = %+v", cards)
+	}
+
+	accounts, err := c.BankAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].IBAN != "IR000000000000000000000001" || accounts[0].BankName != "Melli" {
+		t.Errorf("BankAccounts = %+v", accounts)
+	}
+
+	stranger := srv.Client(wallex.ClientOptions{APIKey: "wrong"})
+	if _, err := stranger.Balances(); !errors.Is(err, wallex.ErrUnauthorized) {
+		t.Errorf("Balances with a wrong key = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestOrders(t *testing.T) {
+	srv, c := newServer(t)
+	srv.SetMarkets(&wallex.Market{Symbol: "BTCTMN"})
+
+	limit, err := c.PlaceOrder(&wallex.OrderParams{
+		Symbol: "BTCTMN", Type: wallex.OrderTypeLimit, Side: wallex.OrderSideBuy,
+		Price: "1000000000", Quantity: "0.5", ClientID: "mine",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit.ClientOrderID != "mine" || limit.Status != "NEW" || !limit.Active || limit.OrigQty != "0.5" {
+		t.Errorf("PlaceOrder = %+v", limit)
+	}
+	// Placing again with the same client id returns the same order.
+	again, err := c.PlaceOrder(&wallex.OrderParams{
+		Symbol: "BTCTMN", Type: wallex.OrderTypeLimit, Side: wallex.OrderSideBuy,
+		Price: "1", Quantity: "1", ClientID: "mine",
+	})
+	if err != nil || again.Price != "1000000000" {
+		t.Errorf("PlaceOrder again = %+v, %v", again, err)
+	}
+
+	market, err := c.PlaceOrder(&wallex.OrderParams{
+		Symbol: "BTCTMN", Type: wallex.OrderTypeMarket, Side: wallex.OrderSideSell, Quantity: "0.25",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if market.Status != "FILLED" || market.Active || market.ExecutedQty == nil || *market.ExecutedQty != "0.25" {
+		t.Errorf("PlaceOrder(market) = %+v", market)
+	}
+
+	_, err = c.PlaceOrder(&wallex.OrderParams{Symbol: "ETHTMN", Type: "STOP", Side: wallex.OrderSideBuy, Quantity: "1"})
+	var e *wallex.Error
+	if !errors.Is(err, wallex.ErrBadRequest) || !errors.As(err, &e) || len(e.Fields["symbol"]) == 0 || len(e.Fields["type"]) == 0 {
+		t.Errorf("PlaceOrder(invalid) = %v, want a bad request on symbol and type", err)
+	}
+
+	if o, err := c.Order("mine"); err != nil || o.Price != "1000000000" {
+		t.Errorf("Order = %+v, %v", o, err)
+	}
+	if _, err := c.Order("nope"); !errors.Is(err, wallex.ErrNotFound) {
+		t.Errorf("Order(nope) = %v, want ErrNotFound", err)
+	}
+
+	open, err := c.OpenOrders("BTCTMN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 1 || open[0].ClientOrderID != "mine" {
+		t.Errorf("OpenOrders = %+v", open)
+	}
+
+	if err := c.CancelOrder("mine"); err != nil {
+		t.Fatal(err)
+	}
+	if open, err := c.OpenOrders(""); err != nil || len(open) != 0 {
+		t.Errorf("OpenOrders after cancel = %+v, %v", open, err)
+	}
+	if err := c.CancelOrder("nope"); !errors.Is(err, wallex.ErrNotFound) {
+		t.Errorf("CancelOrder(nope) = %v, want ErrNotFound", err)
+	}
+	if orders := srv.Orders(); len(orders) != 2 || orders[0].Status != "CANCELED" {
+		t.Errorf("Orders = %+v", orders)
+	}
+}
+
+func TestAddOrderKeepsCopies(t *testing.T) {
+	srv, c := newServer(t)
+	qty := wallex.Number("0")
+	o := &wallex.Order{Symbol: "BTCTMN", Status: "NEW", Active: true, ExecutedQty: &qty}
+	srv.AddOrder(o)
+	if o.ClientOrderID == "" {
+		t.Fatal("AddOrder did not assign a client order id")
+	}
+
+	// The server does not see later changes of the caller...
+	o.Status = "FILLED"
+	qty = "1"
+	if got, err := c.Order(o.ClientOrderID); err != nil || got.Status != "NEW" || *got.ExecutedQty != "0" {
+		t.Errorf("Order = %+v, %v", got, err)
+	}
+
+	// ...and the caller does not see changes of the server.
+	orders := srv.Orders()
+	if err := c.CancelOrder(o.ClientOrderID); err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != "FILLED" || orders[0].Status != "NEW" {
+		t.Errorf("cancel changed orders of the caller: %q and %q", o.Status, orders[0].Status)
+	}
+	orders[0].Status = "EXPIRED"
+	if got := srv.Orders(); got[0].Status != "CANCELED" {
+		t.Errorf("Orders = %+v, want the canceled order", got[0])
+	}
+}
+
+func TestTrades(t *testing.T) {
+	srv, c := newServer(t)
+	srv.SetTrades(
+		&wallex.Trade{Symbol: "BTCTMN", Price: "1", IsBuyer: true},
+		&wallex.Trade{Symbol: "BTCTMN", Price: "2"},
+		&wallex.Trade{Symbol: "USDTTMN", Price: "3", IsBuyer: true},
+	)
+	tests := []struct {
+		symbol, side string
+		want         []wallex.Number
+	}{
+		{"", "", []wallex.Number{"1", "2", "3"}},
+		{"BTCTMN", "", []wallex.Number{"1", "2"}},
+		{"", wallex.OrderSideBuy, []wallex.Number{"1", "3"}},
+		{"BTCTMN", wallex.OrderSideSell, []wallex.Number{"2"}},
+	}
+	for _, tt := range tests {
+		trades, err := c.Trades(tt.symbol, tt.side)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []wallex.Number
+		for _, tr := range trades {
+			got = append(got, tr.Price)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Trades(%q, %q) = %v, want %v", tt.symbol, tt.side, got, tt.want)
+		}
+	}
+}
+
+func TestFail(t *testing.T) {
+	srv, c := newServer(t)
+
+	srv.Fail("/v1/markets", wallextest.Failure{Status: http.StatusServiceUnavailable, Times: 1})
+	if _, err := c.Markets(); !errors.Is(err, wallex.ErrServer) {
+		t.Errorf("first Markets = %v, want ErrServer", err)
+	}
+	if _, err := c.Markets(); err != nil {
+		t.Errorf("second Markets = %v, want the failure consumed", err)
+	}
+	if n := srv.Hits("/v1/markets"); n != 2 {
+		t.Errorf("Hits = %d, want 2", n)
+	}
+
+	srv.Fail("/v1/account/orders", wallextest.Failure{
+		Method: http.MethodDelete,
+		Status: http.StatusTooManyRequests,
+		Body:   `{"success":false,"code":429,"message":"slow down"}`,
+		Header: http.Header{"Retry-After": {"1"}},
+	})
+	err := c.CancelOrder("x")
+	var e *wallex.Error
+	if !errors.Is(err, wallex.ErrTooManyRequests) || !errors.As(err, &e) || e.ServerMessage != "slow down" {
+		t.Errorf("CancelOrder = %v, want the injected 429", err)
+	}
+	// The failure is restricted to DELETE and lasts until cleared.
+	if _, err := c.PlaceOrder(&wallex.OrderParams{Symbol: "BTCTMN", Type: wallex.OrderTypeMarket, Side: wallex.OrderSideBuy, Quantity: "1"}); err != nil {
+		t.Errorf("PlaceOrder = %v", err)
+	}
+	if err := c.CancelOrder("x"); !errors.Is(err, wallex.ErrTooManyRequests) {
+		t.Errorf("second CancelOrder = %v, want the injected 429", err)
+	}
+	srv.ClearFailures()
+	if err := c.CancelOrder("x"); !errors.Is(err, wallex.ErrNotFound) {
+		t.Errorf("CancelOrder after ClearFailures = %v, want ErrNotFound", err)
+	}
+
+	// Without a status the connection is closed. It fails every request,
+	// since the transport retries a GET on a reused connection once.
+	srv.Fail("/v1/trades", wallextest.Failure{})
+	_, err = c.MarketTrades("BTCTMN")
+	if err == nil || errors.As(err, &e) && e.StatusCode != 0 {
+		t.Errorf("MarketTrades = %v, want a failed request without a status", err)
+	}
+}
@@ -0,0 +1,203 @@
+package wallextest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wallexchange/wallex-go/internal/websocket"
+)
+
+// streamConn is a client connected to the fake socket.io feed.
+// Its channels are guarded by Server.mu.
+type streamConn struct {
+	conn     *websocket.Conn
+	channels map[string]bool
+}
+
+// StreamURL returns the URL of the fake streaming endpoint.
+func (s *Server) StreamURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// SetPingInterval sets the heartbeat interval announced to new stream
+// connections. The ping timeout is always the same as the interval.
+func (s *Server) SetPingInterval(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pingInterval = d
+}
+
+// Publish sends data to every stream subscribed to channel, e.g.
+// "BTCTMN@trade", as the real feed does. It returns the number of
+// streams the event was sent to.
+func (s *Server) Publish(channel string, data interface{}) int {
+	payload, err := json.Marshal([]interface{}{"Broadcaster", channel, data})
+	if err != nil {
+		panic(fmt.Sprintf("wallextest: publish %s: %v", channel, err))
+	}
+	packet := append([]byte("42"), payload...)
+
+	var n int
+	for _, sc := range s.subscribers(channel) {
+		if sc.conn.WriteMessage(websocket.TextMessage, packet) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Subscribers returns the number of streams subscribed to channel.
+// Tests use it to wait for subscriptions before publishing.
+func (s *Server) Subscribers(channel string) int {
+	return len(s.subscribers(channel))
+}
+
+// Close shuts down s, closing stream connections too.
+func (s *Server) Close() {
+	s.Server.Close()
+	s.DropStreams()
+}
+
+// DropStreams closes all stream connections, e.g. to test reconnects.
+func (s *Server) DropStreams() {
+	s.mu.Lock()
+	conns := make([]*streamConn, 0, len(s.streams))
+	for sc := range s.streams {
+		conns = append(conns, sc)
+	}
+	s.mu.Unlock()
+
+	for _, sc := range conns {
+		sc.conn.Close()
+	}
+}
+
+func (s *Server) subscribers(channel string) []*streamConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var conns []*streamConn
+	for sc := range s.streams {
+		if sc.channels[channel] {
+			conns = append(conns, sc)
+		}
+	}
+	return conns
+}
+
+// handleStream serves the socket.io endpoint over the websocket transport.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if s.intercept(w, r) {
+		return
+	}
+	// Reject the request as engine.io does, with its error codes.
+	if r.URL.Query().Get("EIO") != "4" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"code":5,"message":"Unsupported protocol version"}`)
+		return
+	}
+	if r.URL.Query().Get("transport") != "websocket" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"code":0,"message":"Transport unknown"}`)
+		return
+	}
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sc := &streamConn{conn: conn, channels: map[string]bool{}}
+	s.mu.Lock()
+	s.streams[sc] = true
+	s.streamSeq++
+	sid := "wallextest-" + strconv.Itoa(s.streamSeq)
+	interval := s.pingInterval
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, sc)
+		s.mu.Unlock()
+	}()
+
+	open, _ := json.Marshal(map[string]interface{}{
+		"sid":          sid,
+		"upgrades":     []string{},
+		"pingInterval": interval.Milliseconds(),
+		"pingTimeout":  interval.Milliseconds(),
+		"maxPayload":   1000000,
+	})
+	if conn.WriteMessage(websocket.TextMessage, append([]byte("0"), open...)) != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	defer wg.Wait()
+	defer close(done)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if conn.WriteMessage(websocket.TextMessage, []byte("2")) != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * interval))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		packet := string(data)
+		switch {
+		case packet == "3":
+		case packet == "40":
+			if conn.WriteMessage(websocket.TextMessage, []byte(`40{"sid":"`+sid+`"}`)) != nil {
+				return
+			}
+		case packet == "41":
+			return
+		case strings.HasPrefix(packet, "42"):
+			s.handleStreamEvent(sc, packet[2:])
+		}
+	}
+}
+
+func (s *Server) handleStreamEvent(sc *streamConn, payload string) {
+	var args []json.RawMessage
+	if json.Unmarshal([]byte(payload), &args) != nil || len(args) < 2 {
+		return
+	}
+	var name string
+	var arg struct {
+		Channel string `json:"channel"`
+	}
+	if json.Unmarshal(args[0], &name) != nil || json.Unmarshal(args[1], &arg) != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch name {
+	case "subscribe":
+		sc.channels[arg.Channel] = true
+	case "unsubscribe":
+		delete(sc.channels, arg.Channel)
+	}
+}
@@ -0,0 +1,235 @@
+package wallex
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WatchEventType is the kind of change reported by a Watcher.
+type WatchEventType int
+
+// List of watch event types.
+const (
+	// PriceChanged is reported when the last price of a market changes.
+	PriceChanged WatchEventType = 1 + iota
+
+	// QuoteChanged is reported when the best bid or ask price changes.
+	QuoteChanged
+
+	// VolumeCrossed is reported when the 24h volume of a market crosses
+	// its threshold, in either direction.
+	VolumeCrossed
+
+	// DirectionFlipped is reported when the dominant side of recent
+	// trades, per Stats.Direction, changes between buy and sell. Polls
+	// where neither side dominates are skipped, so buy, tie and then sell
+	// is a flip too.
+	DirectionFlipped
+)
+
+var watchEventTypeNames = [...]string{
+	PriceChanged:     "price_changed",
+	QuoteChanged:     "quote_changed",
+	VolumeCrossed:    "volume_crossed",
+	DirectionFlipped: "direction_flipped",
+}
+
+func (t WatchEventType) String() string {
+	if t < PriceChanged || t > DirectionFlipped {
+		return fmt.Sprintf("WatchEventType(%d)", int(t))
+	}
+	return watchEventTypeNames[t]
+}
+
+// WatchEvent is a change of the stats of a market between two polls.
+type WatchEvent struct {
+	Type     WatchEventType
+	Symbol   string
+	Time     time.Time
+	Previous *MarketStats
+	Current  *MarketStats
+
+	// Threshold is the crossed volume threshold of VolumeCrossed events.
+	Threshold Number
+}
+
+// WatcherOptions customizes a Watcher.
+type WatcherOptions struct {
+
+	// Symbols restricts the watched markets.
+	// If empty, all markets are watched.
+	Symbols []string
+
+	// Interval is the delay between polls. If zero, it defaults to 5s.
+	Interval time.Duration
+
+	// VolumeThresholds maps symbols to the 24h volume, in base asset,
+	// whose crossing is reported.
+	VolumeThresholds map[string]Number
+
+	// MinBackoff is the delay before polling again after a failure.
+	// It doubles with each consecutive failure up to MaxBackoff.
+	// They default to Interval and 12 times Interval.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnError, if not nil, is called with every failed poll.
+	OnError func(error)
+
+	// Buffer is the capacity of the Events channel. If zero, it defaults
+	// to 256.
+	Buffer int
+}
+
+// Watcher polls the stats of markets and reports their changes.
+// Use Stream for real-time updates.
+type Watcher struct {
+	client  *Client
+	opt     WatcherOptions
+	symbols map[string]bool
+	events  chan *WatchEvent
+	last    map[string]*MarketStats
+	sides   map[string]string // Last dominant side of each market.
+}
+
+// NewWatcher returns a watcher of the markets of c. Call Run to start it.
+func NewWatcher(c *Client, opt WatcherOptions) *Watcher {
+	if opt.Interval <= 0 {
+		opt.Interval = 5 * time.Second
+	}
+	if opt.MinBackoff <= 0 {
+		opt.MinBackoff = opt.Interval
+	}
+	if opt.MaxBackoff <= 0 {
+		opt.MaxBackoff = 12 * opt.Interval
+	}
+	if opt.Buffer <= 0 {
+		opt.Buffer = 256
+	}
+	w := &Watcher{
+		client: c,
+		opt:    opt,
+		events: make(chan *WatchEvent, opt.Buffer),
+		last:   map[string]*MarketStats{},
+		sides:  map[string]string{},
+	}
+	if len(opt.Symbols) > 0 {
+		w.symbols = map[string]bool{}
+		for _, s := range opt.Symbols {
+			w.symbols[s] = true
+		}
+	}
+	return w
+}
+
+// Events returns the channel events are delivered on.
+// It is closed when Run returns.
+func (w *Watcher) Events() <-chan *WatchEvent {
+	return w.events
+}
+
+// Run polls until ctx is done, then closes the Events channel and returns
+// ctx.Err(). The first poll only records the initial stats. Run must be
+// called at most once.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer close(w.events)
+
+	backoff := &RetryPolicy{
+		MinBackoff: w.opt.MinBackoff,
+		MaxBackoff: w.opt.MaxBackoff,
+		Jitter:     0.2,
+	}
+	failures := 0
+	for {
+		delay := w.opt.Interval
+		if err := w.poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if w.opt.OnError != nil {
+				w.opt.OnError(err)
+			}
+			failures++
+			delay = backoff.backoff(failures)
+		} else {
+			failures = 0
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *Watcher) poll(ctx context.Context) error {
+	markets, err := w.client.MarketsContext(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, m := range markets {
+		if w.symbols != nil && !w.symbols[m.Symbol] {
+			continue
+		}
+		cur := m.Stats
+		prev, ok := w.last[m.Symbol]
+		w.last[m.Symbol] = &cur
+		side := w.sides[m.Symbol]
+		if d := dominantSide(&cur); d != "" {
+			w.sides[m.Symbol] = d
+		}
+		if !ok {
+			continue
+		}
+		for _, ev := range w.diff(m.Symbol, prev, &cur, side) {
+			ev.Time = now
+			select {
+			case w.events <- ev:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// diff returns the events between two stats of symbol, where side is the
+// last dominant side before cur.
+func (w *Watcher) diff(symbol string, prev, cur *MarketStats, side string) []*WatchEvent {
+	var events []*WatchEvent
+	add := func(t WatchEventType) *WatchEvent {
+		ev := &WatchEvent{Type: t, Symbol: symbol, Previous: prev, Current: cur}
+		events = append(events, ev)
+		return ev
+	}
+
+	if prev.LastPrice.Cmp(cur.LastPrice) != 0 {
+		add(PriceChanged)
+	}
+	if prev.BidPrice.Cmp(cur.BidPrice) != 0 || prev.AskPrice.Cmp(cur.AskPrice) != 0 {
+		add(QuoteChanged)
+	}
+	if th, ok := w.opt.VolumeThresholds[symbol]; ok && !th.IsUndefined() &&
+		!prev.Volume24H.IsUndefined() && !cur.Volume24H.IsUndefined() {
+		if (prev.Volume24H.Cmp(th) >= 0) != (cur.Volume24H.Cmp(th) >= 0) {
+			add(VolumeCrossed).Threshold = th
+		}
+	}
+	if c := dominantSide(cur); side != "" && c != "" && side != c {
+		add(DirectionFlipped)
+	}
+	return events
+}
+
+// dominantSide returns OrderSideBuy or OrderSideSell, whichever dominates
+// the recent trades of s, or "" if neither does.
+func dominantSide(s *MarketStats) string {
+	switch {
+	case s.Direction.Buy > s.Direction.Sell:
+		return OrderSideBuy
+	case s.Direction.Sell > s.Direction.Buy:
+		return OrderSideSell
+	default:
+		return ""
+	}
+}
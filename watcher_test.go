@@ -0,0 +1,65 @@
+package wallex_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	wallex "github.com/wallexchange/wallex-go"
+	"github.com/wallexchange/wallex-go/wallextest"
+)
+
+func market(price wallex.Number, buy, sell int) *wallex.Market {
+	m := &wallex.Market{Symbol: "BTCTMN"}
+	m.Stats.LastPrice = price
+	m.Stats.Direction.Buy = buy
+	m.Stats.Direction.Sell = sell
+	return m
+}
+
+// nextEvents returns the types of the events of the next changed poll.
+func nextEvents(t *testing.T, w *wallex.Watcher) map[wallex.WatchEventType]bool {
+	t.Helper()
+	types := map[wallex.WatchEventType]bool{}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-w.Events():
+			types[ev.Type] = true
+		case <-time.After(50 * time.Millisecond):
+			if len(types) > 0 {
+				return types
+			}
+		case <-timeout:
+			t.Fatal("no events")
+		}
+	}
+}
+
+func TestWatcherDirectionFlipAcrossTie(t *testing.T) {
+	srv := wallextest.NewServer()
+	defer srv.Close()
+	srv.SetMarkets(market("1", 3, 1))
+
+	w := wallex.NewWatcher(srv.Client(wallex.ClientOptions{}), wallex.WatcherOptions{Interval: 5 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// The second poll starts after the first recorded the buy side.
+	for srv.Hits("/v1/markets") < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	srv.SetMarkets(market("2", 2, 2))
+	if types := nextEvents(t, w); types[wallex.DirectionFlipped] {
+		t.Error("a tie is reported as a flip")
+	}
+	srv.SetMarkets(market("3", 1, 3))
+	if types := nextEvents(t, w); !types[wallex.DirectionFlipped] {
+		t.Errorf("buy, tie and sell is not reported as a flip: %v", types)
+	}
+	srv.SetMarkets(market("4", 1, 4))
+	if types := nextEvents(t, w); types[wallex.DirectionFlipped] {
+		t.Error("sell after sell is reported as a flip")
+	}
+}